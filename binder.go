@@ -1,6 +1,7 @@
 package mars
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -50,6 +51,66 @@ func ValueBinder(f func(value string, typ reflect.Type) reflect.Value) func(*Par
 	}
 }
 
+// CheckedValueBinder is like ValueBinder, but for conversions that may fail.
+// Failures are recorded as a BindError and result in the zero value.
+func CheckedValueBinder(f func(value string, typ reflect.Type) (reflect.Value, error)) func(*Params, string, reflect.Type) reflect.Value {
+	return func(params *Params, name string, typ reflect.Type) reflect.Value {
+		vals, ok := params.Values[name]
+		if !ok || len(vals) == 0 {
+			return reflect.Zero(typ)
+		}
+		result, err := f(vals[0], typ)
+		if err != nil {
+			params.addBindError(name, vals[0], typ, err)
+			return reflect.Zero(typ)
+		}
+		return result
+	}
+}
+
+// A BindError describes a parameter that could not be converted to the
+// requested type. Bind errors of action arguments are added to the
+// controller's Validation, keyed by the parameter's name.
+type BindError struct {
+	Name  string       // Name of the parameter, e.g. "age" or "user.Age"
+	Value string       // The value which could not be converted
+	Type  reflect.Type // The requested type
+	Err   error        // The error returned by the conversion
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("mars/binder: cannot bind %s=%q to %s: %s", e.Name, e.Value, e.Type, e.Err)
+}
+
+// Message returns the message describing the error to users, translated using
+// the message key "mars.bind.int", "mars.bind.float", "mars.bind.time" or
+// "mars.bind.invalid", depending on the requested type, "mars.upload.size"
+// or "mars.upload.type" for rejected uploads, or "mars.body.size" for request
+// bodies too large to be bound, if the application provides it for the given
+// locale.
+func (e *BindError) Message(locale string) string {
+	typ := e.Type
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case errors.Is(e.Err, errUploadTooLarge):
+		return messageOrDefault(locale, "mars.upload.size", "File is too large")
+	case errors.Is(e.Err, errUploadType):
+		return messageOrDefault(locale, "mars.upload.type", "File type is not allowed")
+	case errors.Is(e.Err, errBodyTooLarge):
+		return messageOrDefault(locale, "mars.body.size", "Request body is too large")
+	case typ == reflect.TypeOf(time.Time{}):
+		return messageOrDefault(locale, "mars.bind.time", "Must be a valid date")
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		return messageOrDefault(locale, "mars.bind.int", "Must be a whole number")
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		return messageOrDefault(locale, "mars.bind.float", "Must be a number")
+	}
+	return messageOrDefault(locale, "mars.bind.invalid", "Invalid value")
+}
+
 const (
 	defaultDateFormat     = "2006-01-02"
 	defaultDateTimeFormat = "2006-01-02 15:04"
@@ -69,18 +130,17 @@ var (
 	DateTimeFormat string
 
 	IntBinder = Binder{
-		Bind: ValueBinder(func(val string, typ reflect.Type) reflect.Value {
+		Bind: CheckedValueBinder(func(val string, typ reflect.Type) (reflect.Value, error) {
 			if len(val) == 0 {
-				return reflect.Zero(typ)
+				return reflect.Zero(typ), nil
 			}
-			intValue, err := strconv.ParseInt(val, 10, 64)
+			intValue, err := strconv.ParseInt(val, 10, typ.Bits())
 			if err != nil {
-				WARN.Println(err)
-				return reflect.Zero(typ)
+				return reflect.Value{}, err
 			}
 			pValue := reflect.New(typ)
 			pValue.Elem().SetInt(intValue)
-			return pValue.Elem()
+			return pValue.Elem(), nil
 		}),
 		Unbind: func(output map[string]string, key string, val interface{}) {
 			output[key] = fmt.Sprintf("%d", val)
@@ -88,18 +148,17 @@ var (
 	}
 
 	UintBinder = Binder{
-		Bind: ValueBinder(func(val string, typ reflect.Type) reflect.Value {
+		Bind: CheckedValueBinder(func(val string, typ reflect.Type) (reflect.Value, error) {
 			if len(val) == 0 {
-				return reflect.Zero(typ)
+				return reflect.Zero(typ), nil
 			}
-			uintValue, err := strconv.ParseUint(val, 10, 64)
+			uintValue, err := strconv.ParseUint(val, 10, typ.Bits())
 			if err != nil {
-				WARN.Println(err)
-				return reflect.Zero(typ)
+				return reflect.Value{}, err
 			}
 			pValue := reflect.New(typ)
 			pValue.Elem().SetUint(uintValue)
-			return pValue.Elem()
+			return pValue.Elem(), nil
 		}),
 		Unbind: func(output map[string]string, key string, val interface{}) {
 			output[key] = fmt.Sprintf("%d", val)
@@ -107,18 +166,17 @@ var (
 	}
 
 	FloatBinder = Binder{
-		Bind: ValueBinder(func(val string, typ reflect.Type) reflect.Value {
+		Bind: CheckedValueBinder(func(val string, typ reflect.Type) (reflect.Value, error) {
 			if len(val) == 0 {
-				return reflect.Zero(typ)
+				return reflect.Zero(typ), nil
 			}
-			floatValue, err := strconv.ParseFloat(val, 64)
+			floatValue, err := strconv.ParseFloat(val, typ.Bits())
 			if err != nil {
-				WARN.Println(err)
-				return reflect.Zero(typ)
+				return reflect.Value{}, err
 			}
 			pValue := reflect.New(typ)
 			pValue.Elem().SetFloat(floatValue)
-			return pValue.Elem()
+			return pValue.Elem(), nil
 		}),
 		Unbind: func(output map[string]string, key string, val interface{}) {
 			output[key] = fmt.Sprintf("%f", val)
@@ -163,13 +221,16 @@ var (
 	}
 
 	TimeBinder = Binder{
-		Bind: ValueBinder(func(val string, typ reflect.Type) reflect.Value {
+		Bind: CheckedValueBinder(func(val string, typ reflect.Type) (reflect.Value, error) {
+			if len(val) == 0 {
+				return reflect.Zero(typ), nil
+			}
 			for _, f := range TimeFormats {
 				if r, err := time.Parse(f, val); err == nil {
-					return reflect.ValueOf(r)
+					return reflect.ValueOf(r), nil
 				}
 			}
-			return reflect.Zero(typ)
+			return reflect.Value{}, errors.New("unknown time format")
 		}),
 		Unbind: func(output map[string]string, name string, val interface{}) {
 			var (
@@ -222,6 +283,8 @@ func init() {
 	TypeBinders[reflect.TypeOf([]byte{})] = Binder{bindByteArray, nil}
 	TypeBinders[reflect.TypeOf((*io.Reader)(nil)).Elem()] = Binder{bindReadSeeker, nil}
 	TypeBinders[reflect.TypeOf((*io.ReadSeeker)(nil)).Elem()] = Binder{bindReadSeeker, nil}
+	TypeBinders[multipartReaderType] = Binder{bindMultipartReader, nil}
+	TypeBinders[multipartPartsType] = Binder{bindMultipartParts, nil}
 
 	OnAppStart(func() {
 		DateTimeFormat = Config.StringDefault("format.datetime", defaultDateTimeFormat)
@@ -342,11 +405,12 @@ func bindStruct(params *Params, name string, typ reflect.Type) reflect.Value {
 
 		if _, ok := fieldValues[fieldName]; !ok {
 			// Time to bind this field.  Get it and make sure we can set it.
-			fieldValue := result.FieldByName(fieldName)
-			if !fieldValue.IsValid() {
+			field, found := structFieldByParamName(typ, fieldName)
+			if !found {
 				WARN.Println("W: bindStruct: Field not found:", fieldName)
 				continue
 			}
+			fieldValue := result.FieldByIndex(field.Index)
 			if !fieldValue.CanSet() {
 				WARN.Println("W: bindStruct: Field not settable:", fieldName)
 				continue
@@ -368,15 +432,57 @@ func unbindStruct(output map[string]string, name string, iface interface{}) {
 		fieldValue := val.Field(i)
 
 		// PkgPath is specified to be empty exactly for exported fields.
-		if structField.PkgPath == "" {
-			Unbind(output, fmt.Sprintf("%s.%s", name, structField.Name), fieldValue.Interface())
+		if paramName := structFieldParamName(structField); structField.PkgPath == "" && paramName != "" {
+			Unbind(output, fmt.Sprintf("%s.%s", name, paramName), fieldValue.Interface())
 		}
 	}
 }
 
-// Helper that returns an upload of the given name, or nil.
-func getMultipartFile(params *Params, name string) multipart.File {
+// structFieldParamName returns the name of the parameter a struct field is
+// bound from. It defaults to the field name and can be changed using a tag
+// like `mars:"name"`. Returns an empty string for fields tagged `mars:"-"`,
+// which are never bound.
+func structFieldParamName(field reflect.StructField) string {
+	name := field.Tag.Get("mars")
+	if i := strings.IndexByte(name, ','); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// structFieldByParamName finds the field of the given struct type which is
+// bound from the parameter of the given name.
+func structFieldByParamName(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); structFieldParamName(field) == name {
+			return field, true
+		}
+	}
+
+	// Fields promoted from embedded structs are bound by their name, too.
+	field, ok := typ.FieldByName(name)
+	if !ok || len(field.Index) == 1 || structFieldParamName(field) != name {
+		return reflect.StructField{}, false
+	}
+	return field, true
+}
+
+// Helper that returns an upload of the given name, or nil. Uploads exceeding
+// MaxUploadSize or not matching AllowedUploadTypes are rejected before being
+// read.
+func getMultipartFile(params *Params, name string, typ reflect.Type) multipart.File {
 	for _, fileHeader := range params.Files[name] {
+		if MaxUploadSize > 0 || len(AllowedUploadTypes) > 0 {
+			if _, ok := acceptUpload(params, name, fileHeader, typ); !ok {
+				return nil
+			}
+		}
 		file, err := fileHeader.Open()
 		if err == nil {
 			return file
@@ -387,7 +493,7 @@ func getMultipartFile(params *Params, name string) multipart.File {
 }
 
 func bindFile(params *Params, name string, typ reflect.Type) reflect.Value {
-	reader := getMultipartFile(params, name)
+	reader := getMultipartFile(params, name, typ)
 	if reader == nil {
 		return reflect.Zero(typ)
 	}
@@ -422,8 +528,45 @@ func bindFile(params *Params, name string, typ reflect.Type) reflect.Value {
 	return reflect.ValueOf(tmpFile)
 }
 
+var (
+	multipartReaderType = reflect.TypeOf((*multipart.Reader)(nil))
+	multipartPartsType  = reflect.TypeOf(MultipartParts(nil))
+)
+
+// bindMultipartReader returns the reader for streaming the multipart request
+// body, which is only available to actions opting in to streaming.
+func bindMultipartReader(params *Params, name string, typ reflect.Type) reflect.Value {
+	if params.multipartReader == nil {
+		return reflect.Zero(typ)
+	}
+	return reflect.ValueOf(params.multipartReader)
+}
+
+// bindMultipartParts returns an iterator over the parts of the multipart
+// request body. If the body cannot be read as multipart, the error is yielded
+// first.
+func bindMultipartParts(params *Params, name string, typ reflect.Type) reflect.Value {
+	reader, err := params.multipartReader, params.multipartErr
+	if err != nil {
+		return reflect.ValueOf(MultipartParts(func(yield func(*multipart.Part, error) bool) {
+			yield(nil, err)
+		}))
+	}
+	if reader == nil {
+		return reflect.ValueOf(MultipartParts(func(yield func(*multipart.Part, error) bool) {}))
+	}
+	return reflect.ValueOf(MultipartParts(func(yield func(*multipart.Part, error) bool) {
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF || !yield(part, err) || err != nil {
+				return
+			}
+		}
+	}))
+}
+
 func bindByteArray(params *Params, name string, typ reflect.Type) reflect.Value {
-	if reader := getMultipartFile(params, name); reader != nil {
+	if reader := getMultipartFile(params, name, typ); reader != nil {
 		b, err := ioutil.ReadAll(reader)
 		if err == nil {
 			return reflect.ValueOf(b)
@@ -434,7 +577,7 @@ func bindByteArray(params *Params, name string, typ reflect.Type) reflect.Value
 }
 
 func bindReadSeeker(params *Params, name string, typ reflect.Type) reflect.Value {
-	if reader := getMultipartFile(params, name); reader != nil {
+	if reader := getMultipartFile(params, name, typ); reader != nil {
 		return reflect.ValueOf(reader.(io.ReadSeeker))
 	}
 	return reflect.Zero(typ)
@@ -468,9 +611,15 @@ func unbindMap(output map[string]string, name string, iface interface{}) {
 }
 
 // Bind takes the name and type of the desired parameter and constructs it
-// from one or more values from Params.
+// from one or more values from Params. If the request has a JSON or XML body,
+// parameters missing from the other param maps are decoded from the body.
 // Returns the zero value of the type upon any sort of failure.
 func Bind(params *Params, name string, typ reflect.Type) reflect.Value {
+	if params.JSON != nil || params.XML != nil || params.bodyErr != nil {
+		if value, ok := bindBody(params, name, typ); ok {
+			return value
+		}
+	}
 	if binder, found := binderForType(typ); found {
 		return binder.Bind(params, name, typ)
 	}
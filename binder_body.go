@@ -0,0 +1,163 @@
+package mars
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// errNotInBody signals that the request body does not contain a value for
+// the parameter to be bound.
+var errNotInBody = errors.New("not in request body")
+
+// bindBody constructs the named parameter from the JSON or XML request body:
+//
+//   - If the body is an object (or XML element) with a member named like the
+//     parameter, the parameter is bound from this member, e.g. the action
+//     argument "hotel Hotel" from {"hotel": {"name": "A Hotel"}}.
+//   - Otherwise, structs, maps and slices are bound from the whole body, e.g.
+//     "hotel Hotel" from {"name": "A Hotel"}.
+//
+// Parameters given using the query string, route or form take precedence.
+// Types having their own TypeBinders are not bound from the whole body.
+// Returns false, if the body does not contain the parameter. Values that
+// cannot be decoded are recorded as a BindError and result in the zero value.
+// If the body could not be read or is not a valid JSON object, all parameters
+// not given otherwise get a BindError.
+func bindBody(params *Params, name string, typ reflect.Type) (reflect.Value, bool) {
+	if name == "" || params.hasValues(name) {
+		return reflect.Value{}, false
+	}
+
+	result := reflect.New(typ)
+	var err error
+	switch {
+	case params.bodyErr != nil:
+		err = params.bodyErr
+	case params.JSON != nil:
+		err = bindJSONBody(params, name, result.Interface())
+	default:
+		err = bindXMLBody(params.XML, name, result.Interface())
+	}
+	if err == errNotInBody {
+		return reflect.Value{}, false
+	} else if err != nil {
+		params.addBindError(name, "", typ, err)
+		return reflect.Zero(typ), true
+	}
+	return result.Elem(), true
+}
+
+func bindJSONBody(params *Params, name string, dest interface{}) error {
+	if len(bytes.TrimSpace(params.JSON)) == 0 {
+		return errNotInBody
+	}
+	if params.jsonMembers == nil {
+		// Bodies other than objects result in an empty map. Decoding errors
+		// are kept to be returned for the other parameters as well.
+		params.jsonMembers = make(map[string]json.RawMessage)
+		if bytes.HasPrefix(bytes.TrimSpace(params.JSON), []byte("{")) {
+			if params.bodyErr = json.Unmarshal(params.JSON, &params.jsonMembers); params.bodyErr != nil {
+				return params.bodyErr
+			}
+		}
+	}
+
+	if member, ok := params.jsonMembers[name]; ok {
+		return json.Unmarshal(member, dest)
+	}
+	if bindsWholeBody(reflect.TypeOf(dest).Elem()) {
+		return json.Unmarshal(params.JSON, dest)
+	}
+	return errNotInBody
+}
+
+func bindXMLBody(body []byte, name string, dest interface{}) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errNotInBody
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 1 {
+				if t.Name.Local == name {
+					return decoder.DecodeElement(dest, &t)
+				}
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	if bindsWholeBody(reflect.TypeOf(dest).Elem()) {
+		return xml.Unmarshal(body, dest)
+	}
+	return errNotInBody
+}
+
+// bindsWholeBody checks whether parameters of the given type may be bound from
+// the whole request body.
+func bindsWholeBody(typ reflect.Type) bool {
+	for {
+		if _, ok := TypeBinders[typ]; ok {
+			return false
+		}
+		if typ.Kind() != reflect.Ptr {
+			break
+		}
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
+
+// hasValues checks whether any query string, route, form or file parameters
+// are available to bind the named parameter.
+func (p *Params) hasValues(name string) bool {
+	if _, ok := p.Values[name]; ok {
+		return true
+	}
+	if _, ok := p.Files[name]; ok {
+		return true
+	}
+	for key := range p.Values {
+		if isNestedKey(key, name) {
+			return true
+		}
+	}
+	for key := range p.Files {
+		if isNestedKey(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNestedKey checks whether the key refers to a field or element of the
+// named parameter, e.g. "user.Name" or "ids[0]" for "user" or "ids".
+func isNestedKey(key, name string) bool {
+	return len(key) > len(name) && strings.HasPrefix(key, name) &&
+		(key[len(name)] == '.' || key[len(name)] == '[')
+}
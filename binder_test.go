@@ -298,6 +298,34 @@ var unbinderOverrideAnswers = map[string]map[string]string{
 	"m3": {"m3[a]": "1", "m3[b]": "2"},
 }
 
+func TestBindErrors(t *testing.T) {
+	params := &Params{Values: PARAMS}
+	for _, name := range []string{"invalidInt", "int8-overflow", "uint8-overflow", "invalidInt2", "int"} {
+		Bind(params, name, reflect.TypeOf(int8(0)))
+	}
+	Bind(params, "str", reflect.TypeOf(0.0))
+	Bind(params, "str", reflect.TypeOf(time.Time{}))
+	Bind(params, "B.ID", reflect.TypeOf(0))
+	Bind(params, "B", reflect.TypeOf(A{}))
+
+	expected := []string{"invalidInt", "int8-overflow", "uint8-overflow", "str", "str"}
+	if len(params.bindErrors) != len(expected) {
+		t.Fatalf("Expected %d bind errors, got %v", len(expected), params.bindErrors)
+	}
+	for i, name := range expected {
+		eq(t, "bind error name", params.bindErrors[i].Name, name)
+	}
+	eq(t, "bind error value", params.bindErrors[0].Value, "xyz")
+
+	// Messages may be translated by the application.
+	loadMessages("testdata/i18n")
+	defer func() { messages = nil }()
+	eq(t, "message", params.bindErrors[0].Message("en"), "Must be a whole number")
+	eq(t, "translated message", params.bindErrors[0].Message("nl"), "Moet een geheel getal zijn")
+	eq(t, "float message", params.bindErrors[3].Message("nl"), "Must be a number")
+	eq(t, "time message", params.bindErrors[4].Message("xx"), "Must be a valid date")
+}
+
 func TestUnbinder(t *testing.T) {
 	for k, v := range unbinderTestCases {
 		actual := make(map[string]string)
@@ -63,6 +63,23 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:      "routes",
+			Usage:     "Lists the routes of your application and reports unreachable routes or missing actions",
+			ArgsUsage: "[controllers directory]",
+			Action:    listRoutes,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "r",
+					Value: "conf/routes",
+					Usage: "Path of the routes file",
+				},
+				cli.StringFlag{
+					Name:  "match",
+					Usage: "Shows the route a request like \"GET /path\" resolves to",
+				},
+			},
+		},
 	}
 
 	app.Run(os.Args)
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/roblillack/mars"
+	"github.com/roblillack/mars/internal/pathtree"
+)
+
+// methods used to check whether a route accepting any method can be reached.
+var sampleMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// candidates for wildcard values when building a request matching a route.
+var sampleValues = []string{"sample", "1", "a", "a1", "00000000-0000-0000-0000-000000000000", "sample.txt", "sample/path"}
+
+func listRoutes(ctx *cli.Context) {
+	router, err := mars.LoadRoutes(ctx.String("r"))
+	if err != nil {
+		fatalf("%s", err)
+	}
+
+	if match := ctx.String("match"); match != "" {
+		if !matchRoute(os.Stdout, router, match) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	dir := "app/controllers"
+	if len(ctx.Args()) > 0 {
+		dir = ctx.Args()[0]
+	}
+	var actions map[string]bool
+	if sourceInfo, err := ProcessSource(dir, ctx.GlobalBool("v")); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to check actions: %s\n", err)
+	} else if sourceInfo != nil {
+		actions = controllerActions(sourceInfo)
+	}
+
+	printRoutes(os.Stdout, router.Routes)
+	if problems := checkRoutes(router, actions); len(problems) > 0 {
+		fmt.Println()
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		os.Exit(1)
+	}
+}
+
+// controllerActions returns the lower-cased names of all actions found in the
+// source, e.g. "hotels.show".
+func controllerActions(sourceInfo *SourceInfo) map[string]bool {
+	actions := make(map[string]bool)
+	for _, c := range sourceInfo.ControllerSpecs() {
+		for _, m := range c.MethodSpecs {
+			actions[strings.ToLower(c.StructName+"."+m.Name)] = true
+		}
+	}
+	return actions
+}
+
+func printRoutes(w io.Writer, routes []*mars.Route) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tACTION\tPARAMS\tSOURCE")
+	for _, route := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", route.Method, route.Host+route.Path,
+			route.Action, strings.Join(route.FixedParams, ", "), routeSource(route))
+	}
+	tw.Flush()
+}
+
+func routeSource(route *mars.Route) string {
+	file, line := route.Source()
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// checkRoutes reports routes that can never match, because an earlier route
+// matches all of their requests, as well as routes with unknown actions.
+// If actions is nil, only the built-in controllers are known.
+func checkRoutes(router *mars.Router, actions map[string]bool) []string {
+	var problems []string
+	for _, route := range router.Routes {
+		if shadow := shadowingRoute(router, route); shadow != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s %s can never match, shadowed by %s %s (%s)",
+				routeSource(route), route.Method, route.Host+route.Path,
+				shadow.Method, shadow.Host+shadow.Path, routeSource(shadow)))
+		}
+		if !actionExists(route, actions) {
+			problems = append(problems, fmt.Sprintf("%s: action %s not found", routeSource(route), route.Action))
+		}
+	}
+	return problems
+}
+
+func actionExists(route *mars.Route, actions map[string]bool) bool {
+	if route.Action == "404" || route.ControllerName == "" || route.MethodName == "" ||
+		route.ControllerName[0] == ':' || route.MethodName[0] == ':' {
+		return true
+	}
+	if actions[strings.ToLower(route.ControllerName+"."+route.MethodName)] {
+		return true
+	}
+
+	// Controllers shipped with Mars, like Static
+	var c mars.Controller
+	return c.SetAction(route.ControllerName, route.MethodName) == nil
+}
+
+// shadowingRoute returns the route matching the requests meant for the given
+// one, or nil if the route can be reached. For routes accepting any method,
+// all methods need to be matched by other routes.
+func shadowingRoute(router *mars.Router, route *mars.Route) *mars.Route {
+	host, ok := sampleHost(route.Host)
+	if !ok {
+		return nil
+	}
+	path, ok := samplePath(route.Path)
+	if !ok {
+		return nil
+	}
+
+	methods := []string{route.Method}
+	if route.Method == "*" {
+		methods = sampleMethods
+	}
+
+	var shadow *mars.Route
+	for _, method := range methods {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			return nil
+		}
+		req.Host = host
+		match := router.Route(req)
+		if match == nil || match.Route == nil || match.Route == route {
+			return nil
+		}
+		if shadow == nil {
+			shadow = match.Route
+		}
+	}
+	return shadow
+}
+
+// samplePath builds a request path matching the given route path.
+func samplePath(path string) (string, bool) {
+	elements := strings.Split(path, "/")
+	for i, el := range elements {
+		if el == "" || (el[0] != ':' && el[0] != '*') {
+			continue
+		}
+		name, constraint := pathtree.SplitConstraint(el)
+		extension := ""
+		if dotIdx := strings.IndexRune(name[1:], '.'); dotIdx > 0 {
+			if extension = name[1+dotIdx:]; strings.HasPrefix(extension, ".:") {
+				extension = ".txt"
+			}
+		}
+		val, ok := sampleValue(constraint)
+		if !ok {
+			return "", false
+		}
+		elements[i] = val + extension
+	}
+	return strings.Join(elements, "/"), true
+}
+
+// sampleHost builds a host name matching the given host pattern.
+func sampleHost(host string) (string, bool) {
+	var labels []string
+	start, brackets := 0, 0
+	for i := 0; i <= len(host); i++ {
+		if i < len(host) {
+			if host[i] == '<' {
+				brackets++
+			} else if host[i] == '>' {
+				brackets--
+			}
+			if host[i] != '.' || brackets > 0 {
+				continue
+			}
+		}
+		label := host[start:i]
+		start = i + 1
+		if strings.HasPrefix(label, ":") {
+			_, constraint := pathtree.SplitConstraint(label)
+			val, ok := sampleValue(constraint)
+			if !ok {
+				return "", false
+			}
+			label = val
+		}
+		labels = append(labels, label)
+	}
+	return strings.Join(labels, "."), true
+}
+
+// sampleValue returns a value satisfying the given wildcard constraint.
+func sampleValue(constraint string) (string, bool) {
+	if constraint == "" {
+		return sampleValues[0], true
+	}
+	if named, ok := pathtree.NamedConstraints[constraint]; ok {
+		constraint = named
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return "", false
+	}
+	for _, val := range sampleValues {
+		if re.MatchString(val) {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+// matchRoute prints the route and params a request like "GET /hotels/1"
+// would resolve to. Returns false if no route matches.
+func matchRoute(w io.Writer, router *mars.Router, request string) bool {
+	fields := strings.Fields(request)
+	if len(fields) != 2 {
+		fatalf("Expected request of the form \"METHOD /path\", got: %s", request)
+	}
+	req, err := http.NewRequest(strings.ToUpper(fields[0]), fields[1], nil)
+	if err != nil {
+		fatalf("Invalid request: %s", err)
+	}
+
+	match := router.Route(req)
+	if match == nil {
+		fmt.Fprintf(w, "No route found for %s %s\n", req.Method, fields[1])
+		return false
+	}
+
+	route := match.Route
+	fmt.Fprintf(w, "%s %s %s (%s)\n", route.Method, route.Host+route.Path, route.Action, routeSource(route))
+	if match.Action == "404" {
+		return true
+	}
+	fmt.Fprintf(w, "Action: %s.%s\n", match.ControllerName, match.MethodName)
+	if len(match.FixedParams) > 0 {
+		fmt.Fprintf(w, "Fixed params: %s\n", strings.Join(match.FixedParams, ", "))
+	}
+	if len(match.Attributes) > 0 {
+		attributes := make([]string, 0, len(match.Attributes))
+		for key, value := range match.Attributes {
+			if value != "" {
+				key += "=" + value
+			}
+			attributes = append(attributes, key)
+		}
+		sort.Strings(attributes)
+		fmt.Fprintf(w, "Attributes: %s\n", strings.Join(attributes, " "))
+	}
+	names := make([]string, 0, len(match.Params))
+	for name := range match.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "Param %s: %s\n", name, strings.Join(match.Params[name], ", "))
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roblillack/mars"
+)
+
+const testRoutes = `
+GET     /hotels                 Hotels.Index
+GET     /hotels/:id<int>        Hotels.Show {cache=60s public}
+GET     /hotels/:name           Hotels.ByName
+GET     /hotels/1               Hotels.First
+GET     /public/*filepath       Static.Serve("public")
+GET     /missing                Hotels.Missing
+*       /hotels/:id/book        Hotels.Book
+POST    /hotels/:id/book        Hotels.Book
+*       /:controller/:action    :controller.:action
+`
+
+func loadTestRoutes(t *testing.T) *mars.Router {
+	path := filepath.Join(t.TempDir(), "routes")
+	if err := os.WriteFile(path, []byte(testRoutes), 0600); err != nil {
+		t.Fatal(err)
+	}
+	router, err := mars.LoadRoutes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestSamplePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/hotels":                 "/hotels",
+		"/hotels/:id<int>":        "/hotels/1",
+		"/hotels/:id<uuid>/:slug": "/hotels/00000000-0000-0000-0000-000000000000/sample",
+		"/files/*path":            "/files/sample",
+		"/api/:id.json":           "/api/sample.json",
+		"/api/:id.:ext":           "/api/sample.txt",
+		"/pages/:name<[a-z]+\\d>": "/pages/a1",
+		"/pages/:name<[A-Z]+>":    "",
+	} {
+		actual, ok := samplePath(path)
+		if ok != (expected != "") || actual != expected {
+			t.Errorf("%s: expected %q, got %q", path, expected, actual)
+		}
+	}
+
+	if host, _ := sampleHost(":tenant<[a-z.]+>.example.com"); host != "sample.example.com" {
+		t.Errorf("Unexpected sample host: %s", host)
+	}
+}
+
+func TestCheckRoutes(t *testing.T) {
+	router := loadTestRoutes(t)
+	problems := checkRoutes(router, map[string]bool{
+		"hotels.index":  true,
+		"hotels.show":   true,
+		"hotels.byname": true,
+		"hotels.first":  true,
+		"hotels.book":   true,
+	})
+
+	if len(problems) != 3 {
+		t.Fatalf("Expected 3 problems, got: %v", problems)
+	}
+	for i, expected := range []string{
+		":5: GET /hotels/1 can never match, shadowed by GET /hotels/:id<int>",
+		":7: action Hotels.Missing not found",
+		":9: POST /hotels/:id/book can never match, shadowed by * /hotels/:id/book",
+	} {
+		if !strings.Contains(problems[i], expected) {
+			t.Errorf("Expected problem %q, got %q", expected, problems[i])
+		}
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	router := loadTestRoutes(t)
+
+	var out bytes.Buffer
+	if !matchRoute(&out, router, "GET /hotels/42") {
+		t.Fatal("No route found")
+	}
+	for _, expected := range []string{"GET /hotels/:id<int> Hotels.Show", "Attributes: cache=60s public", "Param id: 42"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output: %s", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	if !matchRoute(&out, router, "GET /public/css/app.css") {
+		t.Fatal("No route found")
+	}
+	for _, expected := range []string{"Fixed params: public", "Param filepath: css/app.css"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output: %s", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	if matchRoute(&out, router, "GET /a/b/c") {
+		t.Errorf("Expected no route, got: %s", out.String())
+	}
+}
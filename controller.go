@@ -26,6 +26,10 @@ type Controller struct {
 	AppController interface{}     // The controller that was instantiated.
 	Action        string          // The fully qualified action name, e.g. "App.Index"
 
+	// RouteAttributes holds the attributes given for the matched route in the
+	// routes file, e.g. {auth=admin cache=60s}. Never modify it.
+	RouteAttributes map[string]string
+
 	Request  *Request
 	Response *Response
 	Result   Result
@@ -233,8 +237,9 @@ func (c *Controller) RenderBinary(memfile io.Reader, filename string, delivery C
 	}
 }
 
-// Redirect returns a result that redirects to an action or to a URL.
+// Redirect returns a result that redirects to an action, a named route or to a URL.
 //   c.Redirect(Controller.Action)
+//   c.Redirect(mars.RouteName("hotel"), id)
 //   c.Redirect("/controller/action")
 //   c.Redirect("/controller/%d/action", id)
 func (c *Controller) Redirect(val interface{}, args ...interface{}) Result {
@@ -246,7 +251,7 @@ func (c *Controller) Redirect(val interface{}, args ...interface{}) Result {
 		}
 		return &RedirectToUrlResult{fmt.Sprintf(url, args...)}
 	}
-	return &RedirectToActionResult{val}
+	return &RedirectToActionResult{val, args}
 }
 
 // Message performs a message lookup for the given message name using the
@@ -1,14 +1,138 @@
 package mars
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strings"
 )
 
 var (
 	cookieKeyValueParser = regexp.MustCompile("\x00([^:]*):([^\x00]*)\x00")
 )
 
+// cookieName returns the full name of the framework cookie with the given
+// name, e.g. "MARS_SESSION" or "__Host-MARS_SESSION" for "SESSION".
+func cookieName(name string) string {
+	return CookieSecurePrefix + CookiePrefix + "_" + name
+}
+
+// NewCookie returns a cookie with the given name and value using the
+// attributes configured for all cookies dropped by the framework. The name is
+// prefixed with CookieSecurePrefix and CookiePrefix, so "SESSION" results in a
+// cookie called "MARS_SESSION" by default. The prefix policies are enforced:
+// "__Secure-" cookies are always secure, "__Host-" cookies additionally have no
+// domain and the path "/".
+func NewCookie(name, value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     cookieName(name),
+		Value:    value,
+		Domain:   CookieDomain,
+		Path:     CookiePath,
+		HttpOnly: CookieHttpOnly,
+		Secure:   CookieSecure,
+		SameSite: CookieSameSite,
+	}
+	if CookieSameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
+	switch CookieSecurePrefix {
+	case "__Host-":
+		cookie.Domain = ""
+		cookie.Path = "/"
+		fallthrough
+	case "__Secure-":
+		cookie.Secure = true
+	}
+	return cookie
+}
+
+// removalCookie returns a cookie removing the framework cookie with the given
+// name from the client.
+func removalCookie(name string) *http.Cookie {
+	cookie := NewCookie(name, "")
+	cookie.MaxAge = -1
+	return cookie
+}
+
+// parseSameSite returns the SameSite mode for the given "cookie.samesite"
+// setting, or def if no mode is given.
+func parseSameSite(value string, def http.SameSite) (http.SameSite, error) {
+	switch value {
+	case "":
+		return def, nil
+	case "default":
+		return http.SameSiteDefaultMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return def, fmt.Errorf("%s, expected default, lax, strict or none", value)
+}
+
+// parseSecurePrefix returns the cookie name prefix for the given
+// "cookie.secureprefix" setting, or def if no setting is given.
+func parseSecurePrefix(value string, def string) (string, error) {
+	switch value {
+	case "":
+		return def, nil
+	case "none":
+		return "", nil
+	case "secure":
+		return "__Secure-", nil
+	case "host":
+		return "__Host-", nil
+	}
+	return def, fmt.Errorf("%s, expected none, secure or host", value)
+}
+
+// keyValueCookieFormat prefixes cookie values which contain URL-encoded keys
+// and values, like "2:lang=de&user=42". As ':' is always escaped within this
+// encoding and values using the legacy "\x00key:value\x00" format always start
+// with "%00", both formats can be told apart.
+const keyValueCookieFormat = "2:"
+
+// encodeKeyValueCookie returns the cookie value for the given keys and values.
+// Contrary to the legacy format, keys and values may contain any character.
+func encodeKeyValueCookie(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	buf.WriteString(keyValueCookieFormat)
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(url.QueryEscape(key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(values[key]))
+	}
+	return buf.String()
+}
+
+// decodeKeyValueCookie takes a cookie value created using encodeKeyValueCookie
+// or in the legacy format and parses out key values.
+func decodeKeyValueCookie(val string, cb func(key, val string)) {
+	if !strings.HasPrefix(val, keyValueCookieFormat) {
+		parseKeyValueCookie(val, cb)
+		return
+	}
+
+	values, _ := url.ParseQuery(val[len(keyValueCookieFormat):])
+	for key, vals := range values {
+		cb(key, vals[0])
+	}
+}
+
 // parseKeyValueCookie takes the raw (escaped) cookie value and parses out key values.
 func parseKeyValueCookie(val string, cb func(key, val string)) {
 	val, _ = url.QueryUnescape(val)
@@ -0,0 +1,105 @@
+package mars
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func resetCookieSettings() {
+	CookiePrefix, CookieDomain, CookiePath = "MARS", "", "/"
+	CookieHttpOnly, CookieSecure = true, false
+	CookieSameSite, CookieSecurePrefix = http.SameSiteLaxMode, ""
+}
+
+func TestNewCookie(t *testing.T) {
+	defer resetCookieSettings()
+
+	cookie := NewCookie("SESSION", "value")
+	if cookie.Name != "MARS_SESSION" || cookie.Value != "value" || cookie.Path != "/" ||
+		!cookie.HttpOnly || cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("unexpected default cookie %+v", cookie)
+	}
+
+	CookieDomain, CookiePath, CookieSameSite = "example.com", "/app", http.SameSiteStrictMode
+	cookie = NewCookie("SESSION", "value")
+	if cookie.Domain != "example.com" || cookie.Path != "/app" || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("expected configured attributes, got %+v", cookie)
+	}
+
+	CookieSameSite = http.SameSiteNoneMode
+	if cookie = NewCookie("SESSION", "value"); !cookie.Secure {
+		t.Errorf("expected SameSite=None cookie to be secure, got %+v", cookie)
+	}
+
+	CookieSameSite, CookieSecurePrefix = http.SameSiteLaxMode, "__Secure-"
+	cookie = NewCookie("SESSION", "value")
+	if cookie.Name != "__Secure-MARS_SESSION" || !cookie.Secure || cookie.Domain != "example.com" {
+		t.Errorf("unexpected __Secure- cookie %+v", cookie)
+	}
+
+	CookieSecurePrefix = "__Host-"
+	cookie = NewCookie("SESSION", "value")
+	if cookie.Name != "__Host-MARS_SESSION" || !cookie.Secure || cookie.Domain != "" || cookie.Path != "/" {
+		t.Errorf("unexpected __Host- cookie %+v", cookie)
+	}
+	if cookie = removalCookie("SESSION"); cookie.Name != "__Host-MARS_SESSION" || cookie.MaxAge != -1 {
+		t.Errorf("unexpected removal cookie %+v", cookie)
+	}
+}
+
+func TestCookieSettings(t *testing.T) {
+	for value, expected := range map[string]http.SameSite{
+		"": http.SameSiteLaxMode, "default": http.SameSiteDefaultMode, "lax": http.SameSiteLaxMode,
+		"strict": http.SameSiteStrictMode, "none": http.SameSiteNoneMode,
+	} {
+		if mode, err := parseSameSite(value, http.SameSiteLaxMode); err != nil || mode != expected {
+			t.Errorf("parseSameSite(%q) = %v, %v", value, mode, err)
+		}
+	}
+	if _, err := parseSameSite("always", http.SameSiteLaxMode); err == nil {
+		t.Error("expected error for invalid SameSite mode")
+	}
+
+	for value, expected := range map[string]string{"": "", "none": "", "secure": "__Secure-", "host": "__Host-"} {
+		if prefix, err := parseSecurePrefix(value, ""); err != nil || prefix != expected {
+			t.Errorf("parseSecurePrefix(%q) = %v, %v", value, prefix, err)
+		}
+	}
+	if _, err := parseSecurePrefix("__Host-", ""); err == nil {
+		t.Error("expected error for invalid prefix policy")
+	}
+}
+
+// All cookies dropped by the framework need to use the configured attributes.
+func TestFrameworkCookies(t *testing.T) {
+	defer resetCookieSettings()
+	CookieDomain, CookieSameSite, CookieSecurePrefix = "example.com", http.SameSiteStrictMode, "__Secure-"
+
+	req, _ := http.NewRequest("POST", "/", nil)
+	recorder := httptest.NewRecorder()
+	c := NewController(NewRequest(req), NewResponse(recorder))
+	c.Params = &Params{}
+	c.Session = make(Session)
+	chain := []Filter{SessionFilter, FlashFilter, ValidationFilter, CSRFFilter, func(c *Controller, _ []Filter) {
+		c.Session["user"] = "Tom"
+		c.Flash.Success("Done")
+		c.Validation.Required("")
+		c.Validation.Keep()
+	}}
+	c.SkipCSRF = true
+	chain[0](c, chain[1:])
+
+	names := map[string]bool{}
+	for _, cookie := range recorder.Result().Cookies() {
+		names[cookie.Name] = true
+		if cookie.Domain != "example.com" || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+			t.Errorf("cookie %s does not use the configured attributes: %+v", cookie.Name, cookie)
+		}
+	}
+	for _, name := range []string{"SESSION", "FLASH", "ERRORS", "CSRF"} {
+		if !names["__Secure-MARS_"+name] {
+			t.Errorf("expected cookie %s to be set, got %v", name, names)
+		}
+	}
+}
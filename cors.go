@@ -0,0 +1,232 @@
+package mars
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures the Cross-Origin Resource Sharing headers added by
+// CORSFilter.
+type CORS struct {
+	AllowedOrigins   []string      // Origins allowed to access resources, like "https://example.com", or "*" for all.
+	AllowedMethods   []string      // Methods allowed for cross-origin requests.
+	AllowedHeaders   []string      // Request headers allowed for cross-origin requests.
+	ExposedHeaders   []string      // Response headers scripts are allowed to read.
+	AllowCredentials bool          // Whether cookies and authentication may be sent with cross-origin requests from the origins listed explicitly, never for ones only matching "*".
+	MaxAge           time.Duration // How long the results of a preflight request may be cached, 0 for the browser default.
+}
+
+// DefaultCORS is the configuration used by CORSFilter. It is configured using
+// these settings in app.conf, where lists are separated by commas:
+//
+//	cors.allowedorigins =
+//	cors.allowedmethods = GET, HEAD, POST, PUT, PATCH, DELETE
+//	cors.allowedheaders = Content-Type, X-CSRF-Token, X-Requested-With
+//	cors.exposedheaders =
+//	cors.allowcredentials = false
+//	cors.maxage = 0
+var DefaultCORS = CORS{
+	AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+	AllowedHeaders: []string{"Content-Type", csrfHeaderName, "X-Requested-With"},
+}
+
+func init() {
+	OnAppStart(func() {
+		cors := &DefaultCORS
+		cors.AllowedOrigins = configList("cors.allowedorigins", cors.AllowedOrigins)
+		cors.AllowedMethods = configList("cors.allowedmethods", cors.AllowedMethods)
+		cors.AllowedHeaders = configList("cors.allowedheaders", cors.AllowedHeaders)
+		cors.ExposedHeaders = configList("cors.exposedheaders", cors.ExposedHeaders)
+		cors.AllowCredentials = Config.BoolDefault("cors.allowcredentials", cors.AllowCredentials)
+		if maxAge, ok := Config.String("cors.maxage"); ok {
+			var err error
+			if cors.MaxAge, err = time.ParseDuration(maxAge); err != nil {
+				panic(fmt.Errorf("cors.maxage invalid: %s", err))
+			}
+		}
+	})
+}
+
+// configList returns the comma-separated list configured for the given key,
+// or def if the key is not set.
+func configList(key string, def []string) []string {
+	value, ok := Config.String(key)
+	if !ok {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// CORSFilter adds the Cross-Origin Resource Sharing headers configured using
+// DefaultCORS to responses for requests from allowed origins. Preflight
+// requests are answered right away, without invoking any action. If no origins
+// are allowed, the filter does nothing.
+//
+// It is not part of the default Filters. To apply it to all actions, add it
+// to mars.Filters before RouterFilter:
+//
+//	mars.Filters = append([]mars.Filter{mars.PanicFilter, mars.CORSFilter}, mars.Filters[1:]...)
+//
+// To allow cross-origin requests for some controllers only, add it to the
+// filter chain of the controllers instead:
+//
+//	mars.FilterController(API{}).Add(mars.CORSFilter)
+//
+// Preflight requests for these controllers' actions are answered by
+// RouterFilter, if there is no explicit OPTIONS route.
+func CORSFilter(c *Controller, fc []Filter) {
+	origin := c.Request.Header.Get("Origin")
+	if origin == "" || !DefaultCORS.allowsOrigin(origin) {
+		// Responses differ for allowed origins, so they must not be cached
+		// for these.
+		if len(DefaultCORS.AllowedOrigins) > 0 {
+			c.Response.Out.Header().Add("Vary", "Origin")
+		}
+		fc[0](c, fc[1:])
+		return
+	}
+
+	if isPreflightRequest(c.Request.Request) {
+		DefaultCORS.answerPreflight(c)
+		return
+	}
+
+	DefaultCORS.setHeaders(c, origin)
+	if len(DefaultCORS.ExposedHeaders) > 0 {
+		c.Response.Out.Header().Set("Access-Control-Expose-Headers", strings.Join(DefaultCORS.ExposedHeaders, ", "))
+	}
+	fc[0](c, fc[1:])
+}
+
+// isPreflightRequest checks whether the request is a CORS preflight request.
+func isPreflightRequest(req *http.Request) bool {
+	return req.Method == "OPTIONS" && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func (cors CORS) allowsOrigin(origin string) bool {
+	return cors.allowsAnyOrigin() || cors.listsOrigin(origin)
+}
+
+func (cors CORS) allowsAnyOrigin() bool {
+	for _, allowed := range cors.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// listsOrigin checks whether the origin is allowed explicitly, not only by
+// using "*".
+func (cors CORS) listsOrigin(origin string) bool {
+	for _, allowed := range cors.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cors CORS) allowsMethod(method string) bool {
+	for _, allowed := range cors.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cors CORS) allowsHeaders(headers string) bool {
+	for _, header := range strings.Split(headers, ",") {
+		if header = strings.TrimSpace(header); header == "" {
+			continue
+		}
+		found := false
+		for _, allowed := range cors.AllowedHeaders {
+			if allowed == "*" || strings.EqualFold(allowed, header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// setHeaders adds the headers needed for both preflight and actual requests.
+// Origins listed explicitly are echoed, which allows using credentials. All
+// other origins allowed using "*" get "*", without credentials, so that any
+// site cannot make requests on behalf of the user.
+func (cors CORS) setHeaders(c *Controller, origin string) {
+	header := c.Response.Out.Header()
+	header.Add("Vary", "Origin")
+	if !cors.listsOrigin(origin) {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if cors.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// answerPreflight answers a preflight request. If the requested method or
+// headers are not allowed, the CORS headers are left out, which makes the
+// browser refuse sending the actual request.
+func (cors CORS) answerPreflight(c *Controller) {
+	c.Response.Status = http.StatusNoContent
+	c.Result = nil
+
+	origin := c.Request.Header.Get("Origin")
+	requestHeaders := c.Request.Header.Get("Access-Control-Request-Headers")
+	if !cors.allowsOrigin(origin) || !cors.allowsMethod(c.Request.Header.Get("Access-Control-Request-Method")) ||
+		!cors.allowsHeaders(requestHeaders) {
+		c.Response.Out.Header().Add("Vary", "Origin")
+		return
+	}
+
+	cors.setHeaders(c, origin)
+	header := c.Response.Out.Header()
+	header.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+	if requestHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if cors.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(cors.MaxAge/time.Second), 10))
+	}
+}
+
+// corsEnabledFor checks whether CORSFilter has been added to the filter chain
+// of the action the given preflight request asks for. If CORSFilter is part of
+// mars.Filters, it has already handled the request before routing.
+func corsEnabledFor(req *http.Request) bool {
+	target := *req
+	target.Method = strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	route := MainRouter.Route(&target)
+	if route == nil || route.Action == "404" {
+		return false
+	}
+
+	var c Controller
+	if err := c.SetAction(route.ControllerName, route.MethodName); err != nil {
+		return false
+	}
+	for _, f := range getOverrideChain(c.Name, c.Action) {
+		if FilterEq(f, CORSFilter) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package mars
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func runCORSFilter(method string, headers map[string]string) (*httptest.ResponseRecorder, bool) {
+	req, _ := http.NewRequest(method, "/hotels", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	c, recorder := newTestController(req)
+	invoked := false
+	CORSFilter(c, []Filter{func(c *Controller, fc []Filter) { invoked = true }})
+	if c.Response.Status != 0 {
+		recorder.WriteHeader(c.Response.Status)
+	}
+	return recorder, invoked
+}
+
+func TestCORSDisabled(t *testing.T) {
+	resp, invoked := runCORSFilter("OPTIONS", map[string]string{
+		"Origin":                        "https://example.com",
+		"Access-Control-Request-Method": "PUT",
+	})
+	if !invoked {
+		t.Error("expected filter chain to continue without allowed origins")
+	}
+	if v := resp.Header().Get("Access-Control-Allow-Origin"); v != "" {
+		t.Errorf("expected no CORS headers, got Access-Control-Allow-Origin: %q", v)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	defer func(cors CORS) { DefaultCORS = cors }(DefaultCORS)
+	DefaultCORS.AllowedOrigins = []string{"https://example.com"}
+	DefaultCORS.AllowCredentials = true
+	DefaultCORS.MaxAge = 10 * time.Minute
+
+	resp, invoked := runCORSFilter("OPTIONS", map[string]string{
+		"Origin":                         "https://example.com",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "content-type, x-csrf-token",
+	})
+	if invoked {
+		t.Error("expected preflight request to be answered by the filter")
+	}
+	eq(t, "status", resp.Code, http.StatusNoContent)
+	for name, value := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://example.com",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST, PUT, PATCH, DELETE",
+		"Access-Control-Allow-Headers":     "content-type, x-csrf-token",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	} {
+		eq(t, name, resp.Header().Get(name), value)
+	}
+
+	// Methods and headers not allowed result in a preflight response without
+	// CORS headers.
+	for _, headers := range []map[string]string{
+		{"Origin": "https://example.com", "Access-Control-Request-Method": "TRACE"},
+		{"Origin": "https://example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "X-Secret"},
+	} {
+		resp, invoked = runCORSFilter("OPTIONS", headers)
+		if invoked {
+			t.Error("expected preflight request to be answered by the filter")
+		}
+		eq(t, "status", resp.Code, http.StatusNoContent)
+		eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "")
+	}
+
+	// Other origins are left alone.
+	resp, invoked = runCORSFilter("OPTIONS", map[string]string{
+		"Origin":                        "https://evil.com",
+		"Access-Control-Request-Method": "PUT",
+	})
+	if !invoked {
+		t.Error("expected filter chain to continue for other origins")
+	}
+	eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestCORSRequest(t *testing.T) {
+	defer func(cors CORS) { DefaultCORS = cors }(DefaultCORS)
+	DefaultCORS.AllowedOrigins = []string{"*"}
+	DefaultCORS.ExposedHeaders = []string{"X-Total-Count"}
+
+	resp, invoked := runCORSFilter("GET", map[string]string{"Origin": "https://example.com"})
+	if !invoked {
+		t.Error("expected filter chain to continue")
+	}
+	eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "*")
+	eq(t, "Access-Control-Expose-Headers", resp.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count")
+	eq(t, "Access-Control-Allow-Credentials", resp.Header().Get("Access-Control-Allow-Credentials"), "")
+	eq(t, "Vary", resp.Header().Get("Vary"), "Origin")
+
+	// Same-origin requests without Origin header do not get any CORS headers.
+	resp, invoked = runCORSFilter("GET", nil)
+	if !invoked {
+		t.Error("expected filter chain to continue")
+	}
+	eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "")
+	eq(t, "Vary", resp.Header().Get("Vary"), "Origin")
+}
+
+func TestCORSCredentials(t *testing.T) {
+	defer func(cors CORS) { DefaultCORS = cors }(DefaultCORS)
+	DefaultCORS.AllowedOrigins = []string{"https://app.example.com", "*"}
+	DefaultCORS.AllowCredentials = true
+
+	// Credentials are only allowed for origins listed explicitly.
+	for origin, expected := range map[string][2]string{
+		"https://app.example.com": {"https://app.example.com", "true"},
+		"https://evil.com":        {"*", ""},
+	} {
+		resp, _ := runCORSFilter("GET", map[string]string{"Origin": origin})
+		eq(t, origin+" Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), expected[0])
+		eq(t, origin+" Access-Control-Allow-Credentials", resp.Header().Get("Access-Control-Allow-Credentials"), expected[1])
+	}
+
+	// Responses for rejected origins vary as well.
+	DefaultCORS.AllowedOrigins = []string{"https://app.example.com"}
+	resp, invoked := runCORSFilter("GET", map[string]string{"Origin": "https://evil.com"})
+	if !invoked {
+		t.Error("expected filter chain to continue")
+	}
+	eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "")
+	eq(t, "Vary", resp.Header().Get("Vary"), "Origin")
+}
+
+func TestCORSFilterController(t *testing.T) {
+	startFakeBookingApp()
+
+	defer func(cors CORS) {
+		DefaultCORS = cors
+		delete(filterOverrides, "Hotels")
+	}(DefaultCORS)
+	DefaultCORS.AllowedOrigins = []string{"https://example.com"}
+
+	preflight := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("OPTIONS", "/hotels/3/booking", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		resp := httptest.NewRecorder()
+		handle(resp, req)
+		return resp
+	}
+
+	resp := preflight()
+	eq(t, "status", resp.Code, http.StatusNoContent)
+	eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "")
+
+	FilterController(Hotels{}).Add(CORSFilter)
+	resp = preflight()
+	eq(t, "status", resp.Code, http.StatusNoContent)
+	eq(t, "Allow", resp.Header().Get("Allow"), "GET, HEAD, OPTIONS")
+	eq(t, "Access-Control-Allow-Origin", resp.Header().Get("Access-Control-Allow-Origin"), "https://example.com")
+	eq(t, "Access-Control-Allow-Methods", resp.Header().Get("Access-Control-Allow-Methods"), "GET, HEAD, POST, PUT, PATCH, DELETE")
+}
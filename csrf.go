@@ -2,10 +2,13 @@ package mars
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -14,6 +17,10 @@ const csrfCookieName = "CSRF"
 const csrfHeaderName = "X-CSRF-Token"
 const csrfFieldName = "_csrf_token"
 
+// csrfTokenLength is the length of the random tokens. Tokens used by all
+// strategies are at least this long.
+const csrfTokenLength = 22
+
 func isSafeMethod(c *Controller) bool {
 	// Methods deemed safe as as defined RFC 7231, section 4.2.1.
 	// TODO: We might think about adding the two other idempotent methods here, too.
@@ -27,18 +34,55 @@ func isSafeMethod(c *Controller) bool {
 	return false
 }
 
+// findCSRFToken returns the token sent with the request, unmasked if needed.
 func findCSRFToken(c *Controller) string {
 	if h := c.Request.Header.Get(csrfHeaderName); h != "" {
-		return h
+		return unmaskCSRFToken(h)
 	}
 
 	if f := c.Params.Get(csrfFieldName); f != "" {
-		return f
+		return unmaskCSRFToken(f)
 	}
 
 	return ""
 }
 
+// maskCSRFToken returns the token XOR-ed with a random one-time pad, preceded
+// by the pad. As the masked token differs for every request, the token cannot
+// be extracted from compressed responses using BREACH-like attacks.
+func maskCSRFToken(token string) string {
+	buf := make([]byte, 2*len(token))
+	if _, err := rand.Read(buf[:len(token)]); err != nil {
+		ERROR.Printf("Error generating CSRF token mask: %s\n", err)
+		return token
+	}
+	for i := range token {
+		buf[len(token)+i] = buf[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// unmaskCSRFToken returns the token masked by maskCSRFToken. Tokens that have
+// not been masked, e.g. the ones read from the CSRF cookie, are returned as is.
+// These decode to less than 2*csrfTokenLength bytes, if at all.
+func unmaskCSRFToken(masked string) string {
+	buf, err := base64.RawURLEncoding.DecodeString(masked)
+	if err != nil || len(buf)%2 != 0 || len(buf) < 2*csrfTokenLength {
+		return masked
+	}
+	n := len(buf) / 2
+	for i := 0; i < n; i++ {
+		buf[n+i] ^= buf[i]
+	}
+	return string(buf[n:])
+}
+
+// csrfTokenMatches compares the given token with the expected one in constant
+// time.
+func csrfTokenMatches(token, expected string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
 func generateRandomToken() string {
 	buf := make([]byte, 16)
 	if _, err := rand.Read(buf); err != nil {
@@ -94,35 +138,101 @@ func generateRandomToken() string {
 // etc.), add an InterceptorMethod to your Controller that sets the
 // Controller.DisableCSRF to `true` for said requests.
 //
+// The way requests are checked depends on the strategy configured as
+// "csrf.strategy" in app.conf:
+//
+//   - "token" (default): The synchronizer token pattern described above,
+//     see CSRFTokenFilter.
+//   - "doublesubmit": A token signed for the session ID is kept in the
+//     `XXX_CSRF` cookie only, not in the session. See CSRFDoubleSubmitFilter.
+//   - "origin": Requests are checked using the `Sec-Fetch-Site`, `Origin`
+//     and `Referer` headers sent by browsers, no token is needed. Behind a
+//     reverse proxy, "csrf.allowedorigins" needs to be set. See
+//     CSRFOriginFilter.
+//
+// The strategy can be changed for individual controllers or actions by
+// replacing CSRFFilter with one of the filters above, e.g. for a JSON API:
+//
+//    mars.FilterController(API{}).
+//      Insert(mars.CSRFOriginFilter, mars.BEFORE, mars.CSRFFilter).
+//      Remove(mars.CSRFFilter)
+//
 // See also:
 // https://tools.ietf.org/html/rfc7231#section-4.2.1
 func CSRFFilter(c *Controller, fc []Filter) {
+	csrfStrategy(c, fc)
+}
+
+// csrfStrategies maps the values of the "csrf.strategy" setting to the
+// filters implementing them.
+var csrfStrategies = map[string]Filter{
+	"token":        CSRFTokenFilter,
+	"doublesubmit": CSRFDoubleSubmitFilter,
+	"origin":       CSRFOriginFilter,
+}
+
+// csrfStrategy is the filter used by CSRFFilter.
+var csrfStrategy Filter = CSRFTokenFilter
+
+// csrfAllowedOrigins are the origins, like "https://example.com", that are
+// accepted by CSRFOriginFilter in addition to the requested host. They may be
+// specified in config as a comma-separated list using "csrf.allowedorigins".
+var csrfAllowedOrigins []string
+
+func init() {
+	OnAppStart(func() {
+		name := Config.StringDefault("csrf.strategy", "token")
+		strategy, ok := csrfStrategies[name]
+		if !ok {
+			panic(fmt.Errorf("csrf.strategy invalid: %s", name))
+		}
+		csrfStrategy = strategy
+
+		csrfAllowedOrigins = nil
+		for _, origin := range strings.Split(Config.StringDefault("csrf.allowedorigins", ""), ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				csrfAllowedOrigins = append(csrfAllowedOrigins, strings.TrimSuffix(origin, "/"))
+			}
+		}
+	})
+}
+
+// csrfCheckNeeded returns whether the request needs to be checked.
+func csrfCheckNeeded(c *Controller) bool {
+	return !isSafeMethod(c) && !c.SkipCSRF
+}
+
+// setCSRFRenderArgs makes the token available to the template engine, masked
+// differently for each request.
+func setCSRFRenderArgs(c *Controller, csrfToken string) {
+	csrfToken = maskCSRFToken(csrfToken)
+	c.RenderArgs["csrfToken"] = csrfToken
+	c.RenderArgs["csrfField"] = template.HTML(`<input type='hidden' name='` + csrfFieldName + `' value='` + csrfToken + `'/>`)
+}
+
+// CSRFTokenFilter implements the synchronizer token pattern described for
+// CSRFFilter: a random token is kept in the session and needs to be sent with
+// each request not deemed safe.
+func CSRFTokenFilter(c *Controller, fc []Filter) {
 	if DisableCSRF {
 		fc[0](c, fc[1:])
 		return
 	}
 
 	csrfToken := c.Session[csrfCookieKey]
-	if len(csrfToken) != 22 {
+	if len(csrfToken) != csrfTokenLength {
 		csrfToken = generateRandomToken()
 		c.Session[csrfCookieKey] = csrfToken
 	}
 
-	c.SetCookie(&http.Cookie{
-		Name:     fmt.Sprintf("%s_%s", CookiePrefix, csrfCookieName),
-		Value:    csrfToken,
-		Domain:   CookieDomain,
-		Path:     "/",
-		HttpOnly: false,
-		Secure:   CookieSecure,
-		Expires:  time.Now().Add(12 * time.Hour).UTC(),
-	})
-	c.RenderArgs["csrfToken"] = csrfToken
-	c.RenderArgs["csrfField"] = template.HTML(`<input type='hidden' name='` + csrfFieldName + `' value='` + csrfToken + `'/>`)
+	cookie := NewCookie(csrfCookieName, csrfToken)
+	cookie.HttpOnly = false
+	cookie.Expires = time.Now().Add(12 * time.Hour).UTC()
+	c.SetCookie(cookie)
+	setCSRFRenderArgs(c, csrfToken)
 
-	if !isSafeMethod(c) && !c.SkipCSRF {
-		token := findCSRFToken(c)
-		if token == "" || token != csrfToken {
+	if csrfCheckNeeded(c) {
+		if !csrfTokenMatches(findCSRFToken(c), csrfToken) {
 			c.Result = c.Forbidden("No/wrong CSRF token given.")
 			return
 		}
@@ -130,3 +240,134 @@ func CSRFFilter(c *Controller, fc []Filter) {
 
 	fc[0](c, fc[1:])
 }
+
+// CSRFDoubleSubmitFilter implements the signed double-submit cookie pattern:
+// A random token signed using the application secret is kept in the
+// `XXX_CSRF` cookie, which lasts until the browser is closed. Requests not
+// deemed safe need to send the same token using the `X-CSRF-Token` header or
+// form field, just like for CSRFTokenFilter. As an attacker can neither read
+// the cookie nor create validly signed tokens, no server-side state is needed.
+// The signature covers the session ID, if the session has one, so that a
+// token planted by an attacker able to set cookies for the domain, e.g. from a
+// sibling subdomain, is not accepted for other sessions. No session ID is
+// created for anonymous requests, instead tokens are replaced when the session
+// ID changes. Tokens signed using a previous secret are signed using the
+// current one when being sent to the client again.
+func CSRFDoubleSubmitFilter(c *Controller, fc []Filter) {
+	if DisableCSRF {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	var received, csrfToken string
+	sessionId := c.Session[SESSION_ID_KEY]
+	if cookie, err := c.Request.Cookie(cookieName(csrfCookieName)); err == nil && validDoubleSubmitToken(cookie.Value, sessionId) {
+		received = cookie.Value
+		csrfToken = signDoubleSubmitToken(received[:strings.IndexByte(received, '.')], sessionId)
+	} else {
+		csrfToken = newDoubleSubmitToken(sessionId)
+	}
+	if csrfToken != received {
+		cookie := NewCookie(csrfCookieName, csrfToken)
+		cookie.HttpOnly = false
+		c.SetCookie(cookie)
+	}
+	setCSRFRenderArgs(c, csrfToken)
+
+	if csrfCheckNeeded(c) {
+		if received == "" || !csrfTokenMatches(findCSRFToken(c), received) {
+			c.Result = c.Forbidden("No/wrong CSRF token given.")
+			return
+		}
+	}
+
+	fc[0](c, fc[1:])
+}
+
+// newDoubleSubmitToken returns a random token followed by its signature for
+// the given session.
+func newDoubleSubmitToken(sessionId string) string {
+	return signDoubleSubmitToken(generateRandomToken(), sessionId)
+}
+
+// signDoubleSubmitToken appends the signature for the given session, created
+// using the current secret, to a random token.
+func signDoubleSubmitToken(token, sessionId string) string {
+	return token + "." + Sign(csrfCookieName+sessionId+"."+token)
+}
+
+func validDoubleSubmitToken(token, sessionId string) bool {
+	sep := strings.IndexByte(token, '.')
+	return sep > 0 && Verify(csrfCookieName+sessionId+"."+token[:sep], token[sep+1:])
+}
+
+// CSRFOriginFilter protects against CSRF attacks by checking where requests
+// not deemed safe come from, using the headers sent by browsers:
+//
+//   - If `Sec-Fetch-Site` is sent, it needs to be `same-origin` or `none`
+//     (e.g. for bookmarks).
+//   - Otherwise, the `Origin` or, if missing, the `Referer` header needs to
+//     match the scheme and host of the request or one of the origins listed
+//     as "csrf.allowedorigins" in app.conf.
+//
+// The scheme of the request is only known to be HTTPS if the TLS connection
+// is terminated by the application itself, forwarding headers like
+// `X-Forwarded-Proto` are not trusted. Applications served using HTTPS by a
+// reverse proxy therefore need to list their own origin, e.g.
+// "https://example.com", as "csrf.allowedorigins".
+//
+// Requests without any of these headers are not sent by browsers and are
+// accepted. No token or cookie is used, which makes this strategy a good fit
+// for JSON APIs.
+func CSRFOriginFilter(c *Controller, fc []Filter) {
+	if !DisableCSRF && csrfCheckNeeded(c) && !sameOriginRequest(c.Request.Request) {
+		c.Result = c.Forbidden("Cross-origin request denied.")
+		return
+	}
+
+	fc[0](c, fc[1:])
+}
+
+// sameOriginRequest checks whether the request has been sent from the
+// application itself or one of the allowed origins.
+func sameOriginRequest(req *http.Request) bool {
+	switch req.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		if referer := req.Header.Get("Referer"); referer != "" {
+			u, err := url.Parse(referer)
+			if err != nil {
+				return false
+			}
+			origin = u.Scheme + "://" + u.Host
+		} else {
+			return origin == ""
+		}
+	}
+
+	for _, allowed := range csrfAllowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, req.Host) &&
+		strings.EqualFold(u.Scheme, requestScheme(req))
+}
+
+// requestScheme returns the scheme used for sending the request to the
+// application. Requests forwarded by a reverse proxy terminating TLS are
+// reported as "http", see CSRFOriginFilter.
+func requestScheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
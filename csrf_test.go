@@ -0,0 +1,226 @@
+package mars
+
+import (
+	"crypto/tls"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type csrfRequest struct {
+	method  string
+	headers map[string]string
+	cookie  *http.Cookie
+	session Session
+}
+
+// runCSRFFilter runs the given filter and returns whether the action was
+// invoked, the controller and the cookie set.
+func runCSRFFilter(filter Filter, r csrfRequest) (bool, *Controller, *http.Cookie) {
+	req, _ := http.NewRequest(r.method, "https://example.com/", nil)
+	req.Host = "example.com"
+	req.TLS = &tls.ConnectionState{}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	if r.cookie != nil {
+		req.AddCookie(r.cookie)
+	}
+	c, recorder := newTestController(req)
+	c.Params = &Params{Values: url.Values{}}
+	c.Session = r.session
+	if c.Session == nil {
+		c.Session = make(Session)
+	}
+
+	invoked := false
+	filter(c, []Filter{func(c *Controller, _ []Filter) { invoked = true }})
+
+	var cookie *http.Cookie
+	if cookies := recorder.Result().Cookies(); len(cookies) > 0 {
+		cookie = cookies[0]
+	}
+	return invoked, c, cookie
+}
+
+func TestCSRFToken(t *testing.T) {
+	_, c, cookie := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "GET"})
+	token := c.Session[csrfCookieKey]
+	if len(token) != 22 || cookie == nil || cookie.Value != token || unmaskCSRFToken(c.RenderArgs["csrfToken"].(string)) != token {
+		t.Fatalf("expected token in session, cookie and render args, got %v %v", c.Session, cookie)
+	}
+
+	if ok, _, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "POST", session: c.Session}); ok {
+		t.Error("expected request without token to be rejected")
+	}
+	if ok, _, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "POST", session: c.Session,
+		headers: map[string]string{csrfHeaderName: token}}); !ok {
+		t.Error("expected request with token to be accepted")
+	}
+	if ok, _, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "POST",
+		headers: map[string]string{csrfHeaderName: token}}); ok {
+		t.Error("expected token of other session to be rejected")
+	}
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	SetAppSecret("Doris doppelte Dosen dampften.")
+
+	_, c, cookie := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "GET"})
+	if cookie == nil || cookie.HttpOnly || !cookie.Expires.IsZero() || unmaskCSRFToken(c.RenderArgs["csrfToken"].(string)) != cookie.Value {
+		t.Fatalf("expected token cookie, got %v", cookie)
+	}
+	if len(c.Session) != 0 {
+		t.Errorf("expected session to stay empty, got %v", c.Session)
+	}
+	session := Session{}
+	session.Id()
+	_, c, cookie = runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "GET", cookie: cookie, session: session})
+	if cookie == nil {
+		t.Fatal("expected anonymous token to be replaced once the session has an ID")
+	}
+	token := cookie.Value
+
+	// The cookie is only issued if missing.
+	if _, _, again := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "GET", cookie: cookie, session: session}); again != nil {
+		t.Errorf("expected no new cookie, got %v", again)
+	}
+
+	if ok, _, _ := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "POST", cookie: cookie, session: session,
+		headers: map[string]string{csrfHeaderName: token}}); !ok {
+		t.Error("expected request with matching token to be accepted")
+	}
+	if ok, _, _ := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "POST", cookie: cookie, session: session,
+		headers: map[string]string{csrfHeaderName: c.RenderArgs["csrfToken"].(string)}}); !ok {
+		t.Error("expected request with masked token to be accepted")
+	}
+	if ok, _, _ := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "POST", cookie: cookie, session: session}); ok {
+		t.Error("expected request without token to be rejected")
+	}
+
+	// Tokens planted for another session are replaced and cannot be used.
+	other := Session{}
+	other.Id()
+	ok, _, replaced := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "POST", cookie: cookie, session: other,
+		headers: map[string]string{csrfHeaderName: token}})
+	if ok || replaced == nil || replaced.Value == token {
+		t.Errorf("expected token of other session to be rejected, got %v", replaced)
+	}
+
+	// Cookies with forged signatures are replaced and cannot be used.
+	forged := "AAAAAAAAAAAAAAAAAAAAAA." + strings.Repeat("A", 43)
+	ok, _, replaced = runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "POST", session: session,
+		cookie:  &http.Cookie{Name: cookie.Name, Value: forged},
+		headers: map[string]string{csrfHeaderName: forged}})
+	if ok || replaced == nil || replaced.Value == forged {
+		t.Errorf("expected forged token to be rejected, got %v", replaced)
+	}
+}
+
+func TestCSRFDoubleSubmitSecretRotation(t *testing.T) {
+	defer SetPreviousAppSecrets()
+
+	SetAppSecret("Old secret")
+	_, _, cookie := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "GET"})
+	token := cookie.Value
+
+	SetAppSecret("New secret")
+	SetPreviousAppSecrets("Old secret")
+	ok, c, resigned := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "POST", cookie: cookie,
+		headers: map[string]string{csrfHeaderName: token}})
+	if !ok {
+		t.Error("expected token signed using the previous secret to be accepted")
+	}
+	if resigned == nil || resigned.Value == token || !strings.HasPrefix(resigned.Value, token[:strings.IndexByte(token, '.')+1]) {
+		t.Fatalf("expected token to be signed using the current secret, got %v", resigned)
+	}
+	if unmaskCSRFToken(c.RenderArgs["csrfToken"].(string)) != resigned.Value {
+		t.Error("expected re-signed token to be rendered")
+	}
+
+	SetPreviousAppSecrets()
+	if _, _, again := runCSRFFilter(CSRFDoubleSubmitFilter, csrfRequest{method: "GET", cookie: resigned}); again != nil {
+		t.Errorf("expected re-signed token to be kept, got %v", again)
+	}
+}
+
+func TestCSRFOrigin(t *testing.T) {
+	csrfAllowedOrigins = []string{"https://app.example.org"}
+	defer func() { csrfAllowedOrigins = nil }()
+
+	for _, tc := range []struct {
+		method  string
+		headers map[string]string
+		allowed bool
+	}{
+		{"GET", map[string]string{"Sec-Fetch-Site": "cross-site"}, true},
+		{"POST", map[string]string{"Sec-Fetch-Site": "same-origin"}, true},
+		{"POST", map[string]string{"Sec-Fetch-Site": "none"}, true},
+		{"POST", map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "https://example.com"}, false},
+		{"POST", map[string]string{"Sec-Fetch-Site": "cross-site"}, false},
+		{"POST", map[string]string{"Origin": "https://example.com"}, true},
+		{"POST", map[string]string{"Origin": "https://evil.com"}, false},
+		{"POST", map[string]string{"Origin": "http://example.com"}, false},
+		{"POST", map[string]string{"Origin": "https://app.example.org"}, true},
+		{"POST", map[string]string{"Origin": "null"}, false},
+		{"POST", map[string]string{"Referer": "https://example.com/form"}, true},
+		{"POST", map[string]string{"Referer": "https://evil.com/example.com"}, false},
+		{"POST", map[string]string{"Referer": "http://example.com/form"}, false},
+		{"POST", map[string]string{}, true},
+	} {
+		if ok, _, _ := runCSRFFilter(CSRFOriginFilter, csrfRequest{method: tc.method, headers: tc.headers}); ok != tc.allowed {
+			t.Errorf("%s %v: expected allowed=%v", tc.method, tc.headers, tc.allowed)
+		}
+	}
+}
+
+func TestCSRFStrategy(t *testing.T) {
+	defer func() { csrfStrategy = CSRFTokenFilter }()
+
+	csrfStrategy = CSRFOriginFilter
+	if ok, _, _ := runCSRFFilter(CSRFFilter, csrfRequest{method: "POST", headers: map[string]string{"Origin": "https://example.com"}}); !ok {
+		t.Error("expected CSRFFilter to use the configured strategy")
+	}
+
+	csrfStrategy = CSRFTokenFilter
+	if ok, _, _ := runCSRFFilter(CSRFFilter, csrfRequest{method: "POST", headers: map[string]string{"Origin": "https://example.com"}}); ok {
+		t.Error("expected CSRFFilter to use the configured strategy")
+	}
+}
+
+func TestMaskedCSRFToken(t *testing.T) {
+	_, c, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "GET"})
+	token := c.Session[csrfCookieKey]
+	masked := c.RenderArgs["csrfToken"].(string)
+	if masked == token || !strings.Contains(string(c.RenderArgs["csrfField"].(template.HTML)), masked) {
+		t.Fatalf("expected masked token in render args, got %s", masked)
+	}
+
+	// Every request gets a differently masked token.
+	_, other, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "GET", session: c.Session})
+	if otherMasked := other.RenderArgs["csrfToken"].(string); otherMasked == masked || unmaskCSRFToken(otherMasked) != token {
+		t.Errorf("expected freshly masked token, got %s and %s", masked, otherMasked)
+	}
+
+	// Masked tokens are accepted as form field and header, raw ones as header.
+	form := NewController(buildEmptyRequest(), nil)
+	form.Params = &Params{Values: url.Values{csrfFieldName: {masked}}}
+	if findCSRFToken(form) != token {
+		t.Error("expected masked form field to be unmasked")
+	}
+	for _, header := range []string{masked, token} {
+		if ok, _, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "POST", session: c.Session,
+			headers: map[string]string{csrfHeaderName: header}}); !ok {
+			t.Errorf("expected request with header %s to be accepted", header)
+		}
+	}
+
+	tampered := []byte(masked)
+	tampered[len(tampered)-5] ^= 1
+	if ok, _, _ := runCSRFFilter(CSRFTokenFilter, csrfRequest{method: "POST", session: c.Session,
+		headers: map[string]string{csrfHeaderName: string(tampered)}}); ok {
+		t.Error("expected tampered token to be rejected")
+	}
+}
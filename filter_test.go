@@ -0,0 +1,13 @@
+package mars
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// newTestController returns a controller for req which records its response,
+// ready to be handed to a filter under test.
+func newTestController(req *http.Request) (*Controller, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	return NewController(NewRequest(req), NewResponse(recorder)), recorder
+}
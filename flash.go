@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // Flash represents a cookie that is overwritten on each request.
@@ -38,7 +39,8 @@ func (f Flash) Success(msg string, args ...interface{}) {
 
 // FlashFilter is a Mars Filter that retrieves and sets the flash cookie.
 // Within Mars, it is available as a Flash attribute on Controller instances.
-// The name of the Flash cookie is set as CookiePrefix + "_FLASH".
+// The name of the Flash cookie is set as CookiePrefix + "_FLASH", preceded by
+// CookieSecurePrefix if set.
 func FlashFilter(c *Controller, fc []Filter) {
 	c.Flash = restoreFlash(c.Request.Request)
 	c.RenderArgs["flash"] = c.Flash.Data
@@ -50,23 +52,29 @@ func FlashFilter(c *Controller, fc []Filter) {
 	for key, value := range c.Flash.Out {
 		flashValue += "\x00" + key + ":" + value + "\x00"
 	}
-	c.SetCookie(&http.Cookie{
-		Name:     CookiePrefix + "_FLASH",
-		Value:    url.QueryEscape(flashValue),
-		HttpOnly: CookieHttpOnly,
-		Secure:   CookieSecure,
-		Path:     "/",
-	})
+	value := url.QueryEscape(flashValue)
+	if EncryptCookies && value != "" {
+		value = encryptedCookiePrefix + Encrypt(value)
+	}
+	c.SetCookie(NewCookie("FLASH", value))
 }
 
-// restoreFlash deserializes a Flash cookie struct from a request.
+// restoreFlash deserializes a Flash cookie struct from a request. Encrypted
+// cookies that cannot be decrypted result in an empty flash.
 func restoreFlash(req *http.Request) Flash {
 	flash := Flash{
 		Data: make(map[string]string),
 		Out:  make(map[string]string),
 	}
-	if cookie, err := req.Cookie(CookiePrefix + "_FLASH"); err == nil {
-		parseKeyValueCookie(cookie.Value, func(key, val string) {
+	if cookie, err := req.Cookie(cookieName("FLASH")); err == nil {
+		value := cookie.Value
+		if strings.HasPrefix(value, encryptedCookiePrefix) {
+			var ok bool
+			if value, ok = Decrypt(value[len(encryptedCookiePrefix):]); !ok {
+				return flash
+			}
+		}
+		parseKeyValueCookie(value, func(key, val string) {
 			flash.Data[key] = val
 		})
 	}
@@ -0,0 +1,59 @@
+package mars
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func runFlashFilter(cookie *http.Cookie, action func(f Flash)) *http.Cookie {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	c, resp := newTestController(req)
+	FlashFilter(c, []Filter{func(c *Controller, _ []Filter) { action(c.Flash) }})
+	return resp.Result().Cookies()[0]
+}
+
+func TestFlash(t *testing.T) {
+	cookie := runFlashFilter(nil, func(f Flash) {
+		f.Success("Saved %d items", 3)
+	})
+	runFlashFilter(cookie, func(f Flash) {
+		if f.Data["success"] != "Saved 3 items" {
+			t.Errorf("expected flash message, got %v", f.Data)
+		}
+	})
+}
+
+func TestEncryptedFlash(t *testing.T) {
+	plain := runFlashFilter(nil, func(f Flash) {
+		f.Error("Plain")
+	})
+
+	EncryptCookies = true
+	defer func() { EncryptCookies = false }()
+
+	cookie := runFlashFilter(nil, func(f Flash) {
+		f.Error("Secret")
+	})
+	if !strings.HasPrefix(cookie.Value, encryptedCookiePrefix) || strings.Contains(cookie.Value, "Secret") {
+		t.Errorf("expected encrypted cookie, got %s", cookie.Value)
+	}
+	runFlashFilter(cookie, func(f Flash) {
+		if f.Data["error"] != "Secret" {
+			t.Errorf("expected encrypted flash message, got %v", f.Data)
+		}
+	})
+	runFlashFilter(plain, func(f Flash) {
+		if f.Data["error"] != "Plain" {
+			t.Errorf("expected plain flash message, got %v", f.Data)
+		}
+	})
+	runFlashFilter(&http.Cookie{Name: cookie.Name, Value: cookie.Value[:len(cookie.Value)-2]}, func(f Flash) {
+		if len(f.Data) != 0 {
+			t.Errorf("expected invalid flash to be ignored, got %v", f.Data)
+		}
+	})
+}
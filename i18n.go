@@ -42,6 +42,23 @@ func MessageLanguages() []string {
 //
 // When either an unknown locale or message is detected, a specially formatted string is returned.
 func Message(locale, message string, args ...interface{}) string {
+	value, err := lookupMessage(locale, message)
+	if err != nil {
+		WARN.Println(err)
+		return fmt.Sprintf(unknownValueFormat, message)
+	}
+
+	if len(args) > 0 {
+		TRACE.Printf("Arguments detected, formatting '%s' with %v", value, args)
+		value = fmt.Sprintf(value, args...)
+	}
+
+	return value
+}
+
+// lookupMessage returns the given message for the locale. For unsupported
+// languages, the default language is used.
+func lookupMessage(locale, message string) (string, error) {
 	language, region := parseLocale(locale)
 
 	messageConfig, knownLanguage := messages[language]
@@ -53,29 +70,20 @@ func Message(locale, message string, args ...interface{}) string {
 
 			messageConfig, knownLanguage = messages[defaultLanguage]
 			if !knownLanguage {
-				WARN.Printf("Unsupported default language for locale '%s' and message '%s'", defaultLanguage, message)
-				return fmt.Sprintf(unknownValueFormat, message)
+				return "", fmt.Errorf("Unsupported default language for locale '%s' and message '%s'", defaultLanguage, message)
 			}
 		} else {
-			WARN.Printf("Unable to find default language option (%s); messages for unsupported locales will never be translated", defaultLanguageOption)
-			return fmt.Sprintf(unknownValueFormat, message)
+			return "", fmt.Errorf("Unable to find default language option (%s); messages for unsupported locales will never be translated", defaultLanguageOption)
 		}
 	}
 
 	// This works because unlike the goconfig documentation suggests it will actually
 	// try to resolve message in DEFAULT if it did not find it in the given section.
-	value, error := messageConfig.String(region, message)
-	if error != nil {
-		WARN.Printf("Unknown message '%s' for locale '%s'", message, locale)
-		return fmt.Sprintf(unknownValueFormat, message)
+	value, err := messageConfig.String(region, message)
+	if err != nil {
+		return "", fmt.Errorf("Unknown message '%s' for locale '%s'", message, locale)
 	}
-
-	if len(args) > 0 {
-		TRACE.Printf("Arguments detected, formatting '%s' with %v", value, args)
-		value = fmt.Sprintf(value, args...)
-	}
-
-	return value
+	return value, nil
 }
 
 // MessageHTML performs a message look-up for the given locale and message using the given arguments
@@ -136,6 +144,21 @@ func MessageHTML(locale, key string, args ...interface{}) template.HTML {
 	return template.HTML(Message(locale, key, safeArgs...))
 }
 
+// messageOrDefault looks up the given message like Message, but returns the
+// given default message if the message is not available for the locale. This
+// allows for messages used by Mars itself to be translated by applications.
+func messageOrDefault(locale, message, defaultMessage string, args ...interface{}) string {
+	value, err := lookupMessage(locale, message)
+	if err != nil {
+		value = defaultMessage
+	}
+
+	if len(args) > 0 {
+		value = fmt.Sprintf(value, args...)
+	}
+	return value
+}
+
 func parseLocale(locale string) (language, region string) {
 	if strings.Contains(locale, "-") {
 		languageAndRegion := strings.Split(locale, "-")
@@ -0,0 +1,94 @@
+package pathtree
+
+import (
+	"strings"
+)
+
+// legacyNode is the map-based tree used before the radix tree, reduced to
+// static elements, colon and star wildcards. It is only kept for comparing
+// the performance of both trees in BenchmarkTree100.
+type legacyNode struct {
+	edges    map[string]*legacyNode
+	wildcard *legacyNode
+	leaf     *legacyLeaf
+	star     *legacyLeaf
+	leafs    int
+}
+
+type legacyLeaf struct {
+	Value interface{}
+	order int
+}
+
+func newLegacy() *legacyNode {
+	return &legacyNode{edges: make(map[string]*legacyNode)}
+}
+
+func (n *legacyNode) Add(key string, val interface{}) {
+	n.leafs++
+	n.add(n.leafs, splitPath(key), val)
+}
+
+func (n *legacyNode) add(order int, elements []string, val interface{}) {
+	if len(elements) == 0 {
+		n.leaf = &legacyLeaf{Value: val, order: order}
+		return
+	}
+
+	var el string
+	el, elements = elements[0], elements[1:]
+	switch el[0] {
+	case ':':
+		if n.wildcard == nil {
+			n.wildcard = newLegacy()
+		}
+		n.wildcard.add(order, elements, val)
+		return
+	case '*':
+		n.star = &legacyLeaf{Value: val, order: order}
+		return
+	}
+
+	e, ok := n.edges[el]
+	if !ok {
+		e = newLegacy()
+		n.edges[el] = e
+	}
+	e.add(order, elements, val)
+}
+
+func (n *legacyNode) Find(key string) (*legacyLeaf, []string) {
+	return n.find(splitPath(key), nil)
+}
+
+func (n *legacyNode) find(elements, exp []string) (leaf *legacyLeaf, expansions []string) {
+	if len(elements) == 0 {
+		return n.leaf, exp
+	}
+
+	var starExpansion string
+	if n.star != nil {
+		starExpansion = strings.Join(elements, "/")
+	}
+
+	var el string
+	el, elements = elements[0], elements[1:]
+	if nextNode, ok := n.edges[el]; ok {
+		leaf, expansions = nextNode.find(elements, exp)
+	}
+
+	if n.wildcard != nil {
+		wildcardExp := append(append(make([]string, 0, len(exp)+1), exp...), el)
+		wildcardLeaf, wildcardExpansions := n.wildcard.find(elements, wildcardExp)
+		if wildcardLeaf != nil && (leaf == nil || leaf.order > wildcardLeaf.order) {
+			leaf = wildcardLeaf
+			expansions = wildcardExpansions
+		}
+	}
+
+	if n.star != nil && (leaf == nil || leaf.order > n.star.order) {
+		leaf = n.star
+		expansions = append(append(make([]string, 0, len(exp)+1), exp...), starExpansion)
+	}
+	return
+}
@@ -25,57 +25,114 @@
 // Additionally, extensions might be variable, too, to allow for paths like
 // `/users/:id.:extension` and `/assets/logo.:ext`.
 //
+// Constraints
+//
+// Wildcards may be followed by a constraint in angle brackets that the expanded
+// value has to satisfy for the path to match, e.g. '/users/:id<int>' or
+// '/pages/:slug<[a-z0-9-]+>'. A constraint is either one of the names listed in
+// NamedConstraints or a regular expression that has to match the whole value.
+// Constraints may not contain a '/'. If a constraint is not satisfied, the
+// lookup continues as if the path had not been added at all, so routes like
+// '/users/:id<int>' and '/users/:name' can coexist.
+//
 // Algorithm
 //
 // Paths are mapped to the tree in the following way:
 //   - Each '/' is a Node in the tree. The root node is the leading '/'.
-//   - Each Node has edges to other nodes. The edges are named according to the
-//     possible path elements at that depth in the path.
+//   - Each Node has edges to other nodes. Static edges are labeled with one or
+//     more path elements: chains of static path elements without anything
+//     else attached to them are compressed into a single edge, which makes
+//     this a radix tree on path elements.
+//   - Wildcard edges are kept separately and are tried after the static ones.
 //   - Any Node may have an associated Leaf.  Leafs are terminals containing the
 //     data associated with the path as traversed from the root to that Node.
 //
-// Edges are implemented as a map from the path element name to the next node in
-// the path.
+// If a path matches more than one leaf, the leaf added first wins. Adding a
+// path that can never be found because of an existing one with the same
+// elements results in an error.
+//
+// Static edges are kept sorted by their first path element, so they can be
+// found using a binary search. Lookups work on the given path without
+// splitting or copying it, so finding a path does not allocate unless the
+// wildcard expansions need to be returned in a newly allocated slice.
 package pathtree
 
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 )
 
+// NamedConstraints maps the names which can be used as wildcard constraints
+// to the regular expressions they stand for.
+var NamedConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
 type Node struct {
-	edges           map[string]*Node // the various path elements leading out of this node.
-	wildcard        *Node            // if set, this node had a wildcard as its path element.
-	leaf            *Leaf            // if set, this is a terminal node for this leaf.
-	extensions      map[string]*Leaf // if set, this is a terminal node with a leaf that ends in a specific extension.
-	wildcardExtLeaf *Leaf            // if set, this is a terminal node with a leaf for wildcard file extensions.
-	star            *Leaf            // if set, this path ends in a star.
-	leafs           int              // counter for # leafs in the tree
+	label           string             // the static path elements leading to this node, e.g. "users/admin"
+	first           string             // the first path element of label
+	static          []*Node            // the static edges leading out of this node, sorted by first element.
+	wildcard        *Node              // if set, this node had a wildcard as its path element.
+	constrained     []*constrainedNode // wildcard nodes with a constraint.
+	leaf            *Leaf              // if set, this is a terminal node for this leaf.
+	extensions      map[string]*Leaf   // if set, this is a terminal node with a leaf that ends in a specific extension.
+	wildcardExtLeaf *Leaf              // if set, this is a terminal node with a leaf for wildcard file extensions.
+	star            *Leaf              // if set, this path ends in a star.
+	leafs           int                // counter for # leafs in the tree
+}
+
+type constrainedNode struct {
+	*Node
+	constraint string // the constraint as given, e.g. "int"
 }
 
 type Leaf struct {
-	Value       interface{} // the value associated with this node
-	Wildcards   []string    // the wildcard names, in order they appear in the path
-	ExtWildcard string      // if set, this is the wildcard used for the file extension
-	order       int         // the order this leaf was added
+	Value       interface{}      // the value associated with this node
+	Wildcards   []string         // the wildcard names, in order they appear in the path
+	ExtWildcard string           // if set, this is the wildcard used for the file extension
+	constraints []*regexp.Regexp // the wildcard constraints, in the same order as Wildcards
+	order       int              // the order this leaf was added
+	key         string           // the path this leaf was added for
+}
+
+// satisfies checks whether the given expansions fulfill the leaf's constraints.
+func (l *Leaf) satisfies(expansions []string) bool {
+	for i, c := range l.constraints {
+		if c != nil && (i >= len(expansions) || !c.MatchString(expansions[i])) {
+			return false
+		}
+	}
+	return true
 }
 
 // New returns a new path tree.
 func New() *Node {
-	return &Node{edges: make(map[string]*Node)}
+	return &Node{}
 }
 
 // Add a path and its associated value to the tree.
 //   - key must begin with "/"
 //   - key must not duplicate any existing key.
+//
 // Returns an error if those conditions do not hold.
 func (n *Node) Add(key string, val interface{}) error {
 	if key == "" || key[0] != '/' {
 		return errors.New("Path must begin with /")
 	}
 	n.leafs++
-	return n.add(n.leafs, splitPath(key), nil, val)
+	return n.add(n.leafs, key, splitPath(key), nil, nil, val)
+}
+
+// conflict returns the error for adding a path that would be hidden by the
+// given leaf.
+func conflict(key string, existing *Leaf) error {
+	return fmt.Errorf("duplicate path: %s conflicts with %s", key, existing.key)
 }
 
 // Adds a leaf to a terminal node.
@@ -83,7 +140,7 @@ func (n *Node) Add(key string, val interface{}) error {
 func (n *Node) addLeaf(leaf *Leaf) error {
 	if leaf.ExtWildcard != "" {
 		if n.wildcardExtLeaf != nil {
-			return errors.New("duplicate path")
+			return conflict(leaf.key, n.wildcardExtLeaf)
 		}
 		n.wildcardExtLeaf = leaf
 		return nil
@@ -94,26 +151,28 @@ func (n *Node) addLeaf(leaf *Leaf) error {
 		if n.extensions == nil {
 			n.extensions = make(map[string]*Leaf)
 		}
-		if n.extensions[extension] != nil {
-			return fmt.Errorf("duplicate path for extension %s", extension)
+		if existing := n.extensions[extension]; existing != nil {
+			return conflict(leaf.key, existing)
 		}
 		n.extensions[extension] = leaf
 		return nil
 	}
 
 	if n.leaf != nil {
-		return errors.New("duplicate path")
+		return conflict(leaf.key, n.leaf)
 	}
 	n.leaf = leaf
 	return nil
 }
 
-func (n *Node) add(order int, elements, wildcards []string, val interface{}) error {
+func (n *Node) add(order int, key string, elements, wildcards []string, constraints []*regexp.Regexp, val interface{}) error {
 	if len(elements) == 0 {
 		leaf := &Leaf{
-			order:     order,
-			Value:     val,
-			Wildcards: wildcards,
+			order:       order,
+			key:         key,
+			Value:       val,
+			Wildcards:   wildcards,
+			constraints: constraints,
 		}
 		if len(wildcards) > 0 {
 			base, ext := extensionForPath(wildcards[len(wildcards)-1])
@@ -125,8 +184,7 @@ func (n *Node) add(order int, elements, wildcards []string, val interface{}) err
 		return n.addLeaf(leaf)
 	}
 
-	var el string
-	el, elements = elements[0], elements[1:]
+	el := elements[0]
 	if el == "" {
 		return errors.New("empty path elements are not allowed")
 	}
@@ -134,130 +192,322 @@ func (n *Node) add(order int, elements, wildcards []string, val interface{}) err
 	// Handle wildcards.
 	switch el[0] {
 	case ':':
-		if n.wildcard == nil {
-			n.wildcard = New()
+		el, constraint := SplitConstraint(el)
+		matcher, err := compileConstraint(constraint)
+		if err != nil {
+			return err
+		}
+		var next *Node
+		if constraint == "" {
+			if n.wildcard == nil {
+				n.wildcard = New()
+			}
+			next = n.wildcard
+		} else {
+			for _, c := range n.constrained {
+				if c.constraint == constraint {
+					next = c.Node
+				}
+			}
+			if next == nil {
+				next = New()
+				n.constrained = append(n.constrained, &constrainedNode{next, constraint})
+			}
 		}
-		return n.wildcard.add(order, elements, append(wildcards, el[1:]), val)
+		return next.add(order, key, elements[1:], append(wildcards, el[1:]), append(constraints, matcher), val)
 	case '*':
+		if len(elements) > 1 {
+			return fmt.Errorf("star wildcard %s must be the last path element", el)
+		}
 		if n.star != nil {
-			return fmt.Errorf("duplicate path: %v %v", elements, wildcards)
+			return conflict(key, n.star)
+		}
+		el, constraint := SplitConstraint(el)
+		matcher, err := compileConstraint(constraint)
+		if err != nil {
+			return err
 		}
 		n.star = &Leaf{
-			order:     order,
-			Value:     val,
-			Wildcards: append(wildcards, el[1:]),
+			order:       order,
+			key:         key,
+			Value:       val,
+			Wildcards:   append(wildcards, el[1:]),
+			constraints: append(constraints, matcher),
 		}
 		return nil
 	}
 
+	// Collect all static path elements up to the next wildcard.
+	run := 1
+	for run < len(elements) && elements[run] != "" && elements[run][0] != ':' && elements[run][0] != '*' {
+		run++
+	}
+
 	// Non-wildcard path element with variable extension, create a "normal" node with
 	// just an ExtWildcard leaf.
-	base, ext := extensionForPath(el)
-	if len(ext) > 2 && ext[1] == ':' {
-		if len(elements) == 0 {
-			el = base
-			wildcards = []string{ext[1:]}
-
-			e, ok := n.edges[base]
-			if !ok {
-				e = New()
-				n.edges[base] = e
+	if run == len(elements) {
+		last := elements[run-1]
+		if base, ext := extensionForPath(last); len(ext) > 2 && ext[1] == ':' {
+			if base == "" {
+				return errors.New("empty path elements are not allowed")
 			}
-
-			return e.addLeaf(&Leaf{
+			segments := append(append(make([]string, 0, run), elements[:run-1]...), base)
+			return n.addStatic(segments).addLeaf(&Leaf{
 				order:       order,
+				key:         key,
 				Value:       val,
-				Wildcards:   wildcards,
+				Wildcards:   []string{ext[1:]},
 				ExtWildcard: ext[2:],
 			})
 		}
 	}
 
 	// It's a normal path element.
-	e, ok := n.edges[el]
-	if !ok {
-		e = New()
-		n.edges[el] = e
+	return n.addStatic(elements[:run]).add(order, key, elements[run:], wildcards, constraints, val)
+}
+
+// addStatic returns the node reached by following the given static path
+// elements, creating and splitting edges as needed.
+func (n *Node) addStatic(elements []string) *Node {
+	for len(elements) > 0 {
+		idx, child := n.findStatic(elements[0])
+		if child == nil {
+			child = &Node{label: strings.Join(elements, "/"), first: elements[0]}
+			n.static = append(n.static, nil)
+			copy(n.static[idx+1:], n.static[idx:])
+			n.static[idx] = child
+			return child
+		}
+
+		// Split the edge, if the path leaves it in the middle.
+		labelElements := strings.Split(child.label, "/")
+		common := 1
+		for common < len(labelElements) && common < len(elements) && labelElements[common] == elements[common] {
+			common++
+		}
+		if common < len(labelElements) {
+			mid := &Node{
+				label:  strings.Join(labelElements[:common], "/"),
+				first:  child.first,
+				static: []*Node{child},
+			}
+			child.label = strings.Join(labelElements[common:], "/")
+			child.first = labelElements[common]
+			n.static[idx] = mid
+			child = mid
+		}
+
+		n, elements = child, elements[common:]
 	}
+	return n
+}
 
-	return e.add(order, elements, wildcards, val)
+// findStatic returns the static edge starting with the given path element
+// or the index it would have to be inserted at.
+func (n *Node) findStatic(el string) (int, *Node) {
+	lo, hi := 0, len(n.static)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if n.static[mid].first < el {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(n.static) && n.static[lo].first == el {
+		return lo, n.static[lo]
+	}
+	return lo, nil
 }
 
 // Find a given path. Any wildcards traversed along the way are expanded and
 // returned, along with the value.
 func (n *Node) Find(key string) (leaf *Leaf, expansions []string) {
+	return n.FindAppend(key, nil)
+}
+
+// FindAppend works like Find, but appends the wildcard expansions to the given
+// slice. Lookups do not allocate at all, if the slice has enough capacity.
+func (n *Node) FindAppend(key string, expansions []string) (*Leaf, []string) {
 	if len(key) == 0 || key[0] != '/' {
-		return nil, nil
+		return nil, expansions
+	}
+
+	// Trailing slashes are inconsequential. Positions beyond the end of the
+	// path mean that there are no path elements left.
+	path, pos := key[1:], 0
+	if path == "" {
+		pos = 1
+	} else if path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
+
+	var scratch [8]string
+	s := search{path: path, result: expansions, base: len(expansions)}
+	s.find(n, pos, scratch[:0])
+	if s.leaf == nil {
+		return nil, expansions
 	}
+	return s.leaf, s.result
+}
 
-	return n.find(splitPath(key), nil)
+// search holds the state of a single lookup. Every leaf matching the path is
+// offered, and the one added first is kept as the result.
+type search struct {
+	path   string   // the path to find, without leading and trailing slash
+	leaf   *Leaf    // the best leaf found so far
+	result []string // the expansions for leaf, appended to the first base elements
+	base   int
+	offers int // number of leafs offered
 }
 
-func (n *Node) find(elements, exp []string) (leaf *Leaf, expansions []string) {
+func (s *search) offer(leaf *Leaf, expansions []string) {
+	s.offers++
+	if s.leaf == nil || leaf.order < s.leaf.order {
+		s.leaf = leaf
+		s.result = append(s.result[:s.base], expansions...)
+	}
+}
 
-	if len(elements) == 0 {
-		if len(exp) > 0 {
-			lastExp := exp[len(exp)-1]
+// element returns the path element starting at pos, the start of the next one,
+// and whether it is the last element.
+func (s *search) element(pos int) (el string, next int, last bool) {
+	end := strings.IndexByte(s.path[pos:], '/')
+	if end == -1 {
+		return s.path[pos:], len(s.path) + 1, true
+	}
+	return s.path[pos : pos+end], pos + end + 1, false
+}
+
+// follow checks whether the path continues with the given edge label at pos
+// and returns the position after it.
+func (s *search) follow(label string, pos int) (int, bool) {
+	rest := s.path[pos:]
+	if !strings.HasPrefix(rest, label) {
+		return 0, false
+	}
+	if len(rest) == len(label) {
+		return len(s.path) + 1, true
+	}
+	if rest[len(label)] == '/' {
+		return pos + len(label) + 1, true
+	}
+	return 0, false
+}
+
+// find offers all leafs below n matching the path from pos on. The expansions
+// of the wildcards traversed so far are given in exp.
+func (s *search) find(n *Node, pos int, exp []string) {
+	depth := len(exp)
+
+	if pos > len(s.path) {
+		if depth > 0 {
+			lastExp := exp[depth-1]
 			base, ext := extensionForPath(lastExp)
 
 			if ext != "" {
 				// If this node has explicit extensions, check if the path matches one.
-				if n.extensions != nil {
-					if leaf := n.extensions[ext]; leaf != nil {
-						exp[len(exp)-1] = base
-						return leaf, exp
+				// The expansions are modified in place and restored afterwards.
+				if leaf := n.extensions[ext]; leaf != nil {
+					exp[depth-1] = base
+					ok := leaf.satisfies(exp)
+					if ok {
+						s.offer(leaf, exp)
+					}
+					exp[depth-1] = lastExp
+					if ok {
+						return
 					}
 				}
 
 				if n.wildcardExtLeaf != nil {
-					exp[len(exp)-1] = base
-					expansions := append(exp, ext[1:])
-					return n.wildcardExtLeaf, expansions
+					exp[depth-1] = base
+					extExp := append(exp, ext[1:])
+					ok := n.wildcardExtLeaf.satisfies(extExp)
+					if ok {
+						s.offer(n.wildcardExtLeaf, extExp)
+					}
+					exp[depth-1] = lastExp
+					if ok {
+						return
+					}
 				}
 			}
 		}
 
-		return n.leaf, exp
-	}
-
-	// If this node has a star, calculate the star expansions in advance.
-	var starExpansion string
-	if n.star != nil {
-		starExpansion = strings.Join(elements, "/")
+		if n.leaf != nil && n.leaf.satisfies(exp) {
+			s.offer(n.leaf, exp)
+		}
+		return
 	}
 
 	// Peel off the next element and look up the associated edge.
-	var el string
-	el, elements = elements[0], elements[1:]
-	if nextNode, ok := n.edges[el]; ok {
-		leaf, expansions = nextNode.find(elements, exp)
+	el, next, last := s.element(pos)
+	offers := s.offers
+	if _, child := n.findStatic(el); child != nil {
+		if after, ok := s.follow(child.label, pos); ok {
+			s.find(child, after, exp)
+		} else if child.wildcardExtLeaf != nil && len(child.label) > len(el) {
+			// Compressed edge ending in a fixed path element with variable extension
+			if base, ext := extensionForPath(s.path[pos:]); ext != "" && base == child.label && strings.IndexByte(ext, '/') == -1 {
+				s.offer(child.wildcardExtLeaf, append(exp, ext[1:]))
+			}
+		}
 	}
 
 	// Handle fixed path elements with variable extension
-	if leaf == nil && len(elements) == 0 {
+	if last && s.offers == offers {
 		if base, ext := extensionForPath(el); ext != "" {
-			if nextNode, ok := n.edges[base]; ok && nextNode.wildcardExtLeaf != nil {
-				return nextNode.wildcardExtLeaf, append(exp, ext[1:])
+			if _, child := n.findStatic(base); child != nil && child.label == base && child.wildcardExtLeaf != nil {
+				s.offer(child.wildcardExtLeaf, append(exp, ext[1:]))
+				return
 			}
 		}
 	}
 
-	// Handle colon
+	// Handle colon, with and without constraints. Constraints are checked
+	// when reaching a leaf, as the expansion might still contain an extension.
 	if n.wildcard != nil {
-		wildcardLeaf, wildcardExpansions := n.wildcard.find(elements, append(exp, el))
-		if wildcardLeaf != nil && (leaf == nil || leaf.order > wildcardLeaf.order) {
-			leaf = wildcardLeaf
-			expansions = wildcardExpansions
-		}
+		s.find(n.wildcard, next, append(exp, el))
+	}
+	for _, c := range n.constrained {
+		s.find(c.Node, next, append(exp, el))
 	}
 
 	// Handle star
-	if n.star != nil && (leaf == nil || leaf.order > n.star.order) {
-		leaf = n.star
-		expansions = append(exp, starExpansion)
+	if n.star != nil && (s.leaf == nil || s.leaf.order > n.star.order) {
+		starExp := append(exp, s.path[pos:])
+		if n.star.satisfies(starExp) {
+			s.offer(n.star, starExp)
+		}
 	}
+}
+
+// SplitConstraint removes the constraint from a wildcard path element and
+// returns it separately, e.g. ":id<int>.json" => ":id.json", "int".
+func SplitConstraint(el string) (string, string) {
+	start := strings.IndexByte(el, '<')
+	end := strings.LastIndexByte(el, '>')
+	if start == -1 || end < start {
+		return el, ""
+	}
+	return el[:start] + el[end+1:], el[start+1 : end]
+}
 
-	return
+// compileConstraint returns the regular expression matching the given
+// constraint, or nil if there is no constraint.
+func compileConstraint(constraint string) (*regexp.Regexp, error) {
+	if constraint == "" {
+		return nil, nil
+	}
+	if named, ok := NamedConstraints[constraint]; ok {
+		constraint = named
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint <%s>: %s", constraint, err)
+	}
+	return re, nil
 }
 
 func extensionForPath(path string) (string, string) {
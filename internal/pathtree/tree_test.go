@@ -3,6 +3,7 @@ package pathtree
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -121,14 +122,119 @@ func TestNonFittingVariableExtensions(t *testing.T) {
 	notfound(t, n, "/first/file")
 }
 
+func TestConstraints(t *testing.T) {
+	n := New()
+
+	n.Add("/users/:id<int>", 1)
+	n.Add("/users/:id<uuid>", 2)
+	n.Add("/users/:name", 3)
+	n.Add("/pages/:slug<[a-z0-9-]+>.html", 4)
+	n.Add("/pages/:slug<[a-z0-9-]+>", 5)
+	n.Add("/files/*path<.*\\.css>", 6)
+	n.Add("/numbers/:num<uint>", 7)
+
+	found(t, n, "/users/123", []string{"123"}, 1)
+	found(t, n, "/users/-5", []string{"-5"}, 1)
+	found(t, n, "/users/0b3b1e8e-7e7c-4d0f-9d6a-2f6a1f5b2c10", []string{"0b3b1e8e-7e7c-4d0f-9d6a-2f6a1f5b2c10"}, 2)
+	found(t, n, "/users/rob", []string{"rob"}, 3)
+	found(t, n, "/pages/about-us.html", []string{"about-us"}, 4)
+	found(t, n, "/pages/about-us", []string{"about-us"}, 5)
+	found(t, n, "/files/a/b/c.css", []string{"a/b/c.css"}, 6)
+	found(t, n, "/numbers/42", []string{"42"}, 7)
+
+	notfound(t, n, "/pages/About")
+	notfound(t, n, "/pages/About.html")
+	notfound(t, n, "/files/a/b/c.js")
+	notfound(t, n, "/numbers/-42")
+	notfound(t, n, "/numbers/42a")
+}
+
+func TestConstraintsFallThrough(t *testing.T) {
+	n := New()
+
+	n.Add("/:id<int>/edit", 1)
+	n.Add("/:name/:action", 2)
+	n.Add("/*rest", 3)
+
+	found(t, n, "/1/edit", []string{"1"}, 1)
+	found(t, n, "/x/edit", []string{"x", "edit"}, 2)
+	found(t, n, "/1/2/3", []string{"1/2/3"}, 3)
+}
+
 func TestErrors(t *testing.T) {
 	n := New()
 	fails(t, n.Add("//", 1), "empty path elements not allowed")
+	fails(t, n.Add("/:id<[a-z>", 1), "invalid constraints not allowed")
+	n.Add("/a/:id<int>", 1)
+	fails(t, n.Add("/a/:other<int>", 2), "duplicate constrained paths not allowed")
+	fails(t, n.Add("/b/*star/c", 3), "path elements after star not allowed")
+
+	err := n.Add("/a/:name<int>/", 4)
+	if err == nil || !strings.Contains(err.Error(), "/a/:name<int>/") || !strings.Contains(err.Error(), "/a/:id<int>") {
+		t.Errorf("expected error to name both paths, got: %v", err)
+	}
 }
 
-func BenchmarkTree100(b *testing.B) {
+func TestCompressedEdges(t *testing.T) {
 	n := New()
-	n.Add("/", "root")
+
+	n.Add("/api/v1/users/list", 1)
+	n.Add("/api/v1/users/:id", 2)
+	n.Add("/api/v1/groups", 3)
+	n.Add("/api/v2", 4)
+	n.Add("/api", 5)
+	n.Add("/api/v1/users/list/all", 6)
+
+	found(t, n, "/api/v1/users/list", nil, 1)
+	found(t, n, "/api/v1/users/list/", nil, 1)
+	found(t, n, "/api/v1/users/1", []string{"1"}, 2)
+	found(t, n, "/api/v1/groups", nil, 3)
+	found(t, n, "/api/v2", nil, 4)
+	found(t, n, "/api", nil, 5)
+	found(t, n, "/api/v1/users/list/all", nil, 6)
+
+	notfound(t, n, "/api/v1")
+	notfound(t, n, "/api/v1/users")
+	notfound(t, n, "/api/v1/user")
+	notfound(t, n, "/api/v1/users/1/all")
+	notfound(t, n, "/api/v1/users/list/al")
+	notfound(t, n, "/api/v1/groupsx")
+	notfound(t, n, "/api/v3")
+}
+
+func TestFindAppend(t *testing.T) {
+	n := New()
+	n.Add("/users/:id/posts/:post", 1)
+	n.Add("/assets/logo.:ext", 2)
+
+	buf := make([]string, 0, 4)
+	allocs := testing.AllocsPerRun(100, func() {
+		leaf, expansions := n.FindAppend("/users/1/posts/2", buf)
+		if leaf == nil || len(expansions) != 2 {
+			t.Fatalf("unexpected result: %v %v", leaf, expansions)
+		}
+		if leaf, _ = n.FindAppend("/assets/logo.png", buf); leaf == nil {
+			t.Fatalf("unexpected result: %v", leaf)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+
+	leaf, expansions := n.FindAppend("/users/1/posts/2", []string{"prefix"})
+	if leaf == nil || !reflect.DeepEqual(expansions, []string{"prefix", "1", "2"}) {
+		t.Errorf("expected expansions to be appended, got %v", expansions)
+	}
+	leaf, expansions = n.FindAppend("/unknown", []string{"prefix"})
+	if leaf != nil || !reflect.DeepEqual(expansions, []string{"prefix"}) {
+		t.Errorf("expected expansions to be unchanged, got %v", expansions)
+	}
+}
+
+// tree100Keys returns the keys of 100 literal routes, wildcards at each level
+// and a star wildcard, which are added in this order.
+func tree100Keys() []string {
+	keys := []string{"/"}
 
 	// Exact matches
 	for i := 0; i < 100; i++ {
@@ -138,8 +244,7 @@ func BenchmarkTree100(b *testing.B) {
 			key += fmt.Sprintf("/dir%d", j)
 		}
 		key += fmt.Sprintf("/resource%d", i)
-		n.Add(key, "literal")
-		// b.Logf("Adding %s", key)
+		keys = append(keys, key)
 	}
 
 	// Wildcards at each level if no exact matches work.
@@ -148,13 +253,32 @@ func BenchmarkTree100(b *testing.B) {
 		for j := 0; j < i; j++ {
 			key += fmt.Sprintf("/dir%d", j)
 		}
-		key += "/:var"
-		n.Add(key, "var")
-		// b.Logf("Adding %s", key)
+		keys = append(keys, key+"/:var")
+	}
+
+	return append(keys, "/public/*filepath")
+}
+
+func tree100Value(key string) string {
+	switch {
+	case key == "/":
+		return "root"
+	case strings.Contains(key, ":"):
+		return "var"
+	case strings.Contains(key, "*"):
+		return "static"
 	}
+	return "literal"
+}
 
-	n.Add("/public/*filepath", "static")
-	// b.Logf("Adding /public/*filepath")
+// BenchmarkTree100 compares lookups using the radix tree with the ones using
+// the map-based tree it replaced.
+func BenchmarkTree100(b *testing.B) {
+	n, legacy := New(), newLegacy()
+	for _, key := range tree100Keys() {
+		n.Add(key, tree100Value(key))
+		legacy.Add(key, tree100Value(key))
+	}
 
 	queries := map[string]string{
 		"/":                                 "root",
@@ -177,15 +301,37 @@ func BenchmarkTree100(b *testing.B) {
 				query, answer, leaf.Value.(string))
 			return
 		}
+		if leaf, _ := legacy.Find(query); leaf == nil || leaf.Value.(string) != answer {
+			b.Errorf("Incorrect answer of legacy tree for querY %s", query)
+			return
+		}
 	}
 
-	b.ResetTimer()
-
-	for i := 0; i < b.N/len(queries); i++ {
-		for k := range queries {
-			n.Find(k)
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N/len(queries); i++ {
+			for k := range queries {
+				legacy.Find(k)
+			}
 		}
-	}
+	})
+	b.Run("radix", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N/len(queries); i++ {
+			for k := range queries {
+				n.Find(k)
+			}
+		}
+	})
+	b.Run("radix-append", func(b *testing.B) {
+		b.ReportAllocs()
+		expansions := make([]string, 0, 8)
+		for i := 0; i < b.N/len(queries); i++ {
+			for k := range queries {
+				_, expansions = n.FindAppend(k, expansions[:0])
+			}
+		}
+	})
 }
 
 func notfound(t *testing.T, n *Node, p string) {
@@ -29,6 +29,25 @@ func ActionInvoker(c *Controller, _ []Filter) {
 		methodArgs = append(methodArgs, boundArg)
 	}
 
+	// Report arguments that could not be bound, so the action can tell invalid
+	// values apart from missing ones, and check the `validate` struct tags of
+	// the other ones.
+	if c.Validation != nil {
+		var unbound map[string]bool
+		for _, err := range c.Params.bindErrors {
+			c.Validation.Error(err.Message(c.Request.Locale)).Key(err.Name)
+			if unbound == nil {
+				unbound = make(map[string]bool)
+			}
+			unbound[err.Name] = true
+		}
+		for i, arg := range c.MethodType.Args {
+			if arg.Type != websocketType && mayContainTags(arg.Type) {
+				c.Validation.validateValue(arg.Name, methodArgs[i], unbound)
+			}
+		}
+	}
+
 	var resultValue reflect.Value
 	if methodValue.Type().IsVariadic() {
 		resultValue = methodValue.CallSlice(methodArgs)[0]
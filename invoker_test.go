@@ -1,6 +1,7 @@
 package mars
 
 import (
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
@@ -78,6 +79,27 @@ func TestSetAction(t *testing.T) {
 	}
 }
 
+func TestInvokerBindErrors(t *testing.T) {
+	startFakeBookingApp()
+	c := Controller{
+		RenderArgs: make(map[string]interface{}),
+		Validation: &Validation{},
+	}
+	if err := c.SetAction("Hotels", "Show"); err != nil {
+		t.Fatalf("Failed to set action: %s", err)
+	}
+	c.Request = NewRequest(showRequest)
+	c.Response = NewResponse(httptest.NewRecorder())
+	c.Params = &Params{Values: url.Values{"id": {"abc"}}}
+	ActionInvoker(&c, nil)
+
+	err, ok := c.Validation.ErrorMap()["id"]
+	if !ok {
+		t.Fatalf("Expected validation error for id, got %v", c.Validation.Errors)
+	}
+	eq(t, "message", err.Message, "Must be a whole number")
+}
+
 func BenchmarkSetAction(b *testing.B) {
 	type Mixin1 struct {
 		*Controller
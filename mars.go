@@ -5,10 +5,12 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/agtorre/gocolorize"
@@ -98,6 +100,16 @@ var CookiePrefix = "MARS"
 var CookieDomain = ""
 var CookieHttpOnly = true
 var CookieSecure = false
+var CookiePath = "/"
+
+// CookieSameSite is the SameSite attribute of all cookies dropped by the
+// framework. Cookies using http.SameSiteNoneMode are always marked secure.
+var CookieSameSite = http.SameSiteLaxMode
+
+// CookieSecurePrefix is prepended to the names of all cookies dropped by the
+// framework. Use "__Secure-" to make browsers only accept the cookies via
+// HTTPS, or "__Host-" to additionally lock them to the host and path "/".
+var CookieSecurePrefix = ""
 
 func init() {
 	log.SetFlags(defaultLoggerFlags)
@@ -185,6 +197,15 @@ func Init(mode string) {
 	// Enable "secure" flag for cookies whenever HTTPS-only mode is enabled
 	CookieSecure = Config.BoolDefault("cookie.secure", CookieSecure || (HttpSsl && !DualStackHTTP))
 	CookieHttpOnly = Config.BoolDefault("cookie.httponly", CookieHttpOnly)
+	CookiePath = Config.StringDefault("cookie.path", CookiePath)
+
+	var err error
+	if CookieSameSite, err = parseSameSite(Config.StringDefault("cookie.samesite", ""), CookieSameSite); err != nil {
+		log.Fatalln("Invalid cookie.samesite:", err)
+	}
+	if CookieSecurePrefix, err = parseSecurePrefix(Config.StringDefault("cookie.secureprefix", ""), CookieSecurePrefix); err != nil {
+		log.Fatalln("Invalid cookie.secureprefix:", err)
+	}
 }
 
 // InitDefaults initializes Mars based on runtime-loading of config files.
@@ -204,6 +225,15 @@ func Setup() {
 	if s := Config.StringDefault("app.secret", ""); s != "" {
 		SetAppSecret(s)
 	}
+	if s := Config.StringDefault("app.secret.previous", ""); s != "" {
+		var secrets []string
+		for _, secret := range strings.Split(s, ",") {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				secrets = append(secrets, secret)
+			}
+		}
+		SetPreviousAppSecrets(secrets...)
+	}
 
 	// Configure logging
 	if !Config.BoolDefault("log.colorize", true) {
@@ -271,17 +301,25 @@ func SetupViews() {
 // using RegisterController.
 func SetupRouter() {
 	MainRouter = NewRouter(filepath.Join(BasePath, RoutesFile))
-	if err := MainRouter.Refresh(); err != nil {
-		ERROR.Fatalln(err)
-	}
+	MainRouter.TrailingSlash = parsePathPolicy("router.trailingslash", "strict", "strip", PathLenient)
+	MainRouter.CaseInsensitive = parsePathPolicy("router.caseinsensitive", "false", "true", PathStrict)
 
 	// If desired (or by default), create a watcher for templates and routes.
-	// The watcher calls Refresh() on things on the first request.
+	// The watcher calls Refresh() on things on the first request. The router
+	// registers the routes file and all included ones itself.
 	if mainWatcher != nil && Config.BoolDefault("watch.routes", true) {
-		if err := mainWatcher.Listen(MainRouter, MainRouter.path); err != nil {
+		MainRouter.watcher = mainWatcher
+	}
+
+	for _, add := range pendingRoutes {
+		if err := add(MainRouter); err != nil {
 			ERROR.Fatalln(err)
 		}
 	}
+
+	if err := MainRouter.Refresh(); err != nil {
+		ERROR.Fatalln(err)
+	}
 }
 
 // Create a logger using log.* directives in app.conf plus the current settings
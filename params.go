@@ -1,10 +1,19 @@
 package mars
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"iter"
 	"mime/multipart"
+	"net/http"
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 )
 
 // Params provides a unified view of the request params.
@@ -12,6 +21,7 @@ import (
 // - URL query string
 // - Form values
 // - File uploads
+// - JSON and XML request bodies
 //
 // Warning: param maps other than Values may be nil if there were none.
 type Params struct {
@@ -27,6 +37,48 @@ type Params struct {
 
 	Files    map[string][]*multipart.FileHeader // Files uploaded in a multipart form
 	tmpFiles []*os.File                         // Temp files used during the request.
+
+	JSON []byte // The request body, if it has been sent as JSON.
+	XML  []byte // The request body, if it has been sent as XML.
+
+	jsonMembers map[string]json.RawMessage // The members of a JSON object body, once decoded.
+	bodyErr     error                      // Error reading or decoding the body, reported for all parameters bound from it.
+	bindErrors  []*BindError               // Parameters that could not be bound.
+
+	multipartReader *multipart.Reader // Reader for streaming a multipart body, see MultipartParts.
+	multipartErr    error             // Error preparing the multipart body to be streamed.
+}
+
+// MultipartMaxMemory is the number of bytes of a multipart request body kept in
+// memory, with the rest of the uploaded files being stored in temporary files.
+// It may be specified in config as "http.multipart.maxmemory" and defaults to
+// 32 MB.
+var MultipartMaxMemory int64 = 32 << 20
+
+// MultipartParts iterates over the parts of a multipart request body while it
+// is being received, without parsing it in advance:
+//
+//	func (c Videos) Upload(parts mars.MultipartParts) mars.Result {
+//	    for part, err := range parts {
+//	        if err != nil {
+//	            return c.RenderError(err)
+//	        }
+//	        // Read the part, e.g. using io.Copy()
+//	    }
+//	    ...
+//	}
+//
+// Actions having an argument of type MultipartParts or *multipart.Reader opt in
+// to this streaming mode: Form values and files are not available in Params
+// then, as they are part of the stream, and CSRF tokens need to be sent using
+// the X-CSRF-Token header. The body is still limited by "http.maxrequestsize".
+type MultipartParts iter.Seq2[*multipart.Part, error]
+
+func init() {
+	OnAppStart(func() {
+		MultipartMaxMemory = int64(Config.IntDefault("http.multipart.maxmemory", int(MultipartMaxMemory)))
+		MaxBodySize = int64(Config.IntDefault("http.maxbodysize", int(MaxBodySize)))
+	})
 }
 
 func ParseParams(params *Params, req *Request) {
@@ -44,18 +96,85 @@ func ParseParams(params *Params, req *Request) {
 
 	case "multipart/form-data":
 		// Multipart form.
-		// TODO: Extract the multipart form param so app can set it.
-		if err := req.ParseMultipartForm(32 << 20 /* 32 MB */); err != nil {
+		if err := req.ParseMultipartForm(MultipartMaxMemory); err != nil {
 			WARN.Println("Error parsing request body:", err)
 		} else {
 			params.Form = req.MultipartForm.Value
 			params.Files = req.MultipartForm.File
 		}
+
+	default:
+		// JSON or XML body, like application/json or application/atom+xml.
+		switch bodyFormat(req.ContentType) {
+		case "json":
+			params.JSON, params.bodyErr = readBody(req)
+		case "xml":
+			params.XML, params.bodyErr = readBody(req)
+		}
 	}
 
 	params.Values = params.calcValues()
 }
 
+// bodyFormat returns "json" or "xml" for content types used to send JSON or
+// XML documents, or an empty string.
+func bodyFormat(contentType string) string {
+	switch {
+	case contentType == "application/json", contentType == "text/json", strings.HasSuffix(contentType, "+json"):
+		return "json"
+	case contentType == "application/xml", contentType == "text/xml", strings.HasSuffix(contentType, "+xml"):
+		return "xml"
+	}
+	return ""
+}
+
+// MaxBodySize is the maximum size of JSON and XML request bodies read for
+// binding parameters, like the limit of http.Request.ParseForm. Larger bodies
+// result in a BindError for all parameters bound from the body, but can still
+// be read by the action. It may be specified in config as "http.maxbodysize"
+// and defaults to 10 MB.
+var MaxBodySize int64 = 10 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody reads the request body. The body is put back afterwards, so that
+// actions are still able to read it. Bodies larger than MaxBodySize or
+// "http.maxrequestsize" are not read completely and result in errBodyTooLarge.
+func readBody(req *Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, MaxBodySize+1))
+	var maxBytesErr *http.MaxBytesError
+	if (err == nil && int64(len(body)) > MaxBodySize) || errors.As(err, &maxBytesErr) {
+		err = errBodyTooLarge
+	}
+	if err != nil {
+		WARN.Println("Error reading request body:", err)
+		req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// BindJSON decodes the JSON request body into "dest".
+func (p *Params) BindJSON(dest interface{}) error {
+	if p.JSON == nil {
+		return errors.New("mars/params: no JSON request body")
+	}
+	return json.Unmarshal(p.JSON, dest)
+}
+
+// BindXML decodes the XML request body into "dest".
+func (p *Params) BindXML(dest interface{}) error {
+	if p.XML == nil {
+		return errors.New("mars/params: no XML request body")
+	}
+	return xml.Unmarshal(p.XML, dest)
+}
+
+func (p *Params) addBindError(name, value string, typ reflect.Type, err error) {
+	p.bindErrors = append(p.bindErrors, &BindError{Name: name, Value: value, Type: typ, Err: err})
+}
+
 // Bind looks for the named parameter, converts it to the requested type, and
 // writes it into "dest", which must be settable.  If the value can not be
 // parsed, "dest" is set to the zero value.
@@ -109,8 +228,38 @@ func (p *Params) calcValues() url.Values {
 	return values
 }
 
+// parseStreamingParams prepares the multipart body to be read by the action
+// instead of parsing it.
+func parseStreamingParams(params *Params, req *Request) {
+	params.Query = req.URL.Query()
+	reader, err := req.MultipartReader()
+	if err != nil {
+		WARN.Println("Error reading multipart request body:", err)
+	}
+	params.multipartReader, params.multipartErr = reader, err
+	params.Values = params.calcValues()
+}
+
+// streamsMultipart checks whether the action opted in to streaming the
+// multipart request body.
+func streamsMultipart(method *MethodType) bool {
+	if method == nil {
+		return false
+	}
+	for _, arg := range method.Args {
+		if arg.Type == multipartPartsType || arg.Type == multipartReaderType {
+			return true
+		}
+	}
+	return false
+}
+
 func ParamsFilter(c *Controller, fc []Filter) {
-	ParseParams(c.Params, c.Request)
+	if c.Request.ContentType == "multipart/form-data" && streamsMultipart(c.MethodType) {
+		parseStreamingParams(c.Params, c.Request)
+	} else {
+		ParseParams(c.Params, c.Request)
+	}
 
 	// Clean up from the request.
 	defer func() {
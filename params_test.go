@@ -3,10 +3,15 @@ package mars
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -120,6 +125,105 @@ func TestMultipartForm(t *testing.T) {
 	}
 }
 
+func TestMultipartMaxMemory(t *testing.T) {
+	defer func(maxMemory int64) { MultipartMaxMemory = maxMemory }(MultipartMaxMemory)
+
+	for _, tc := range []struct {
+		maxMemory int64
+		tempFiles bool
+	}{
+		{32 << 20, false},
+		{1, true},
+	} {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+		MultipartMaxMemory = tc.maxMemory
+
+		c := Controller{
+			Request: NewRequest(getMultipartRequest()),
+			Params:  &Params{},
+		}
+		ParamsFilter(&c, []Filter{func(c *Controller, _ []Filter) {
+			entries, _ := os.ReadDir(tmpDir)
+			if (len(entries) > 0) != tc.tempFiles {
+				t.Errorf("Expected temp files for max memory %d: %v, got %d", tc.maxMemory, tc.tempFiles, len(entries))
+			}
+		}})
+	}
+}
+
+func runStreamingParamsFilter(argType reflect.Type, req *http.Request, action func(arg interface{})) *Controller {
+	c, _ := newTestController(req)
+	c.Params = &Params{}
+	c.MethodType = &MethodType{Name: "Upload", Args: []*MethodArg{{Name: "upload", Type: argType}}}
+	ParamsFilter(c, []Filter{func(c *Controller, _ []Filter) {
+		action(Bind(c.Params, "upload", argType).Interface())
+	}})
+	return c
+}
+
+func TestMultipartStreaming(t *testing.T) {
+	var names []string
+	c := runStreamingParamsFilter(reflect.TypeOf(MultipartParts(nil)), getMultipartRequest(), func(arg interface{}) {
+		for part, err := range arg.(MultipartParts) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, part.FormName())
+		}
+	})
+	expected := []string{"text1", "text2", "text2", "file1", "file2[]", "file2[]", "file3[0]", "file3[1]"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected parts %v, got %v", expected, names)
+	}
+	if c.Params.Form != nil || c.Params.Files != nil {
+		t.Error("Expected multipart body not to be parsed in streaming mode")
+	}
+
+	runStreamingParamsFilter(reflect.TypeOf((*multipart.Reader)(nil)), getMultipartRequest(), func(arg interface{}) {
+		part, err := arg.(*multipart.Reader).NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		eq(t, "first part", part.FormName(), "text1")
+	})
+
+	// The request size limit applies to the stream.
+	req := getMultipartRequest()
+	req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 100)
+	var streamErr error
+	runStreamingParamsFilter(reflect.TypeOf(MultipartParts(nil)), req, func(arg interface{}) {
+		for part, err := range arg.(MultipartParts) {
+			if err == nil {
+				_, err = io.Copy(io.Discard, part)
+			}
+			if err != nil {
+				streamErr = err
+				break
+			}
+		}
+	})
+	if streamErr == nil {
+		t.Error("Expected error streaming a body exceeding the size limit")
+	}
+
+	// Errors preparing the stream are yielded.
+	req = getMultipartRequest()
+	req.Header.Set("Content-Type", "multipart/form-data")
+	streamErr = nil
+	runStreamingParamsFilter(reflect.TypeOf(MultipartParts(nil)), req, func(arg interface{}) {
+		for part, err := range arg.(MultipartParts) {
+			if part != nil || err == nil {
+				t.Errorf("Expected only an error, got %v, %v", part, err)
+			}
+			streamErr = err
+		}
+	})
+	if streamErr == nil {
+		t.Error("Expected error streaming a body without boundary")
+	}
+}
+
 func TestBind(t *testing.T) {
 	params := Params{
 		Values: url.Values{
@@ -133,6 +237,124 @@ func TestBind(t *testing.T) {
 	}
 }
 
+type bodyHotel struct {
+	Name  string `json:"name" xml:"name"`
+	Stars int    `json:"stars" xml:"stars"`
+}
+
+func parseBodyParams(contentType, body, query string) *Params {
+	req, _ := http.NewRequest("POST", "http://localhost/hotels?"+query, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	params := &Params{}
+	ParseParams(params, NewRequest(req))
+	return params
+}
+
+func TestJSONBody(t *testing.T) {
+	// Whole body
+	params := parseBodyParams("application/json; charset=utf-8", `{"name": "A Hotel", "stars": 4}`, "")
+	eq(t, "whole body", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{"A Hotel", 4})
+	eq(t, "whole body pointer", *Bind(params, "hotel", reflect.TypeOf(&bodyHotel{})).Interface().(*bodyHotel), bodyHotel{"A Hotel", 4})
+	eq(t, "keyed", Bind(params, "stars", reflect.TypeOf(0)).Interface(), 4)
+	eq(t, "missing", Bind(params, "city", reflect.TypeOf("")).Interface(), "")
+
+	var hotel bodyHotel
+	if err := params.BindJSON(&hotel); err != nil || hotel.Name != "A Hotel" {
+		t.Errorf("BindJSON failed: %v, %v", hotel, err)
+	}
+
+	// Keyed by argument name, with query parameters taking precedence.
+	params = parseBodyParams("application/vnd.api+json", `{"hotel": {"name": "A Hotel"}, "ids": [1, 2], "limit": 5}`, "limit=10")
+	eq(t, "keyed struct", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{Name: "A Hotel"})
+	if ids := Bind(params, "ids", reflect.TypeOf([]int{})).Interface(); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("keyed slice: %v", ids)
+	}
+	eq(t, "query", Bind(params, "limit", reflect.TypeOf(0)).Interface(), 10)
+
+	// Arrays
+	params = parseBodyParams("application/json", `[{"name": "A"}, {"name": "B"}]`, "")
+	if hotels := Bind(params, "hotels", reflect.TypeOf([]bodyHotel{})).Interface(); !reflect.DeepEqual(hotels, []bodyHotel{{Name: "A"}, {Name: "B"}}) {
+		t.Errorf("array: %v", hotels)
+	}
+
+	// Invalid bodies result in zero values and bind errors for all arguments.
+	params = parseBodyParams("application/json", `{"name": `, "")
+	eq(t, "invalid", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{})
+	eq(t, "invalid keyed", Bind(params, "stars", reflect.TypeOf(0)).Interface(), 0)
+	if len(params.bindErrors) != 2 || params.bindErrors[1].Name != "stars" || params.bindErrors[1].Err != params.bindErrors[0].Err {
+		t.Errorf("Expected decoding error for both arguments, got %v", params.bindErrors)
+	}
+
+	// Empty bodies do not contain any parameters.
+	params = parseBodyParams("application/json", " \n", "")
+	eq(t, "empty", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{})
+	eq(t, "empty errors", len(params.bindErrors), 0)
+}
+
+func TestBodyReadableAfterParsing(t *testing.T) {
+	startFakeBookingApp()
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 64
+
+	for _, body := range []string{`{"name": "A Hotel"}`, `{"name": "` + strings.Repeat("x", int(MaxBodySize)) + `"}`} {
+		req, _ := http.NewRequest("POST", "http://localhost/hotels", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		c := NewController(NewRequest(req), NewResponse(httptest.NewRecorder()))
+		ParamsFilter(c, []Filter{func(c *Controller, _ []Filter) {
+			content, err := ioutil.ReadAll(c.Request.Body)
+			if err != nil || string(content) != body {
+				t.Errorf("Expected action to read the body of %d bytes, got %d: %v", len(body), len(content), err)
+			}
+		}})
+		eq(t, "JSON parsed", c.Params.JSON != nil, int64(len(body)) <= MaxBodySize)
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 16
+
+	params := parseBodyParams("application/json", `{"hotel": {"name": "A Hotel"}, "stars": 4}`, "limit=10")
+	eq(t, "JSON", params.JSON == nil, true)
+	eq(t, "hotel", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{})
+	eq(t, "stars", Bind(params, "stars", reflect.TypeOf(0)).Interface(), 0)
+	eq(t, "query", Bind(params, "limit", reflect.TypeOf(0)).Interface(), 10)
+	if len(params.bindErrors) != 2 {
+		t.Fatalf("Expected bind errors for body arguments, got %v", params.bindErrors)
+	}
+	for _, err := range params.bindErrors {
+		eq(t, err.Name+" message", err.Message("en"), "Request body is too large")
+	}
+
+	// The limit of http.maxrequestsize is reported the same way.
+	MaxBodySize = 10 << 20
+	req, _ := http.NewRequest("POST", "http://localhost/hotels", strings.NewReader(`{"name": "A Hotel"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 8)
+	params = &Params{}
+	ParseParams(params, NewRequest(req))
+	Bind(params, "hotel", reflect.TypeOf(bodyHotel{}))
+	if len(params.bindErrors) != 1 || params.bindErrors[0].Err != errBodyTooLarge {
+		t.Errorf("Expected body too large, got %v", params.bindErrors)
+	}
+}
+
+func TestXMLBody(t *testing.T) {
+	params := parseBodyParams("application/xml", `<hotel><name>A Hotel</name><stars>4</stars></hotel>`, "")
+	eq(t, "whole body", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{"A Hotel", 4})
+	eq(t, "keyed", Bind(params, "stars", reflect.TypeOf(0)).Interface(), 4)
+	eq(t, "missing", Bind(params, "city", reflect.TypeOf("")).Interface(), "")
+
+	params = parseBodyParams("text/xml", `<request><hotel><name>A Hotel</name></hotel><limit>5</limit></request>`, "")
+	eq(t, "keyed struct", Bind(params, "hotel", reflect.TypeOf(bodyHotel{})).Interface(), bodyHotel{Name: "A Hotel"})
+	eq(t, "keyed int", Bind(params, "limit", reflect.TypeOf(0)).Interface(), 5)
+
+	var hotel bodyHotel
+	if err := params.BindJSON(&hotel); err == nil {
+		t.Error("expected BindJSON to fail for XML body")
+	}
+}
+
 func TestResolveAcceptLanguage(t *testing.T) {
 	request := buildHttpRequestWithAcceptLanguage("")
 	if result := ResolveAcceptLanguage(request); result != nil {
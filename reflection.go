@@ -1,6 +1,7 @@
 package mars
 
 import (
+	"errors"
 	"reflect"
 )
 
@@ -16,3 +17,32 @@ func findMethod(recvType reflect.Type, funcVal reflect.Value) *reflect.Method {
 	}
 	return nil
 }
+
+// actionName returns the action name (e.g. "Controller.Method") for the given
+// value, which can either be the name itself or a method reference like
+// Controller.Method or (*Controller).Method.
+func actionName(action interface{}) (string, error) {
+	if name, ok := action.(string); ok {
+		return name, nil
+	}
+	if action == nil {
+		return "", errors.New("no action given")
+	}
+
+	val := reflect.ValueOf(action)
+	typ := val.Type()
+	if typ.Kind() != reflect.Func || typ.NumIn() == 0 {
+		return "", errors.New("didn't recognize type: " + typ.String())
+	}
+
+	recvType := typ.In(0)
+	method := findMethod(recvType, val)
+	if method == nil {
+		return "", errors.New("couldn't find method")
+	}
+	for recvType.Kind() == reflect.Ptr {
+		recvType = recvType.Elem()
+	}
+
+	return recvType.Name() + "." + method.Name, nil
+}
@@ -9,7 +9,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -360,12 +359,16 @@ func (r *RedirectToUrlResult) Apply(req *Request, resp *Response) {
 	resp.WriteHeader(http.StatusFound, "")
 }
 
+// RouteName refers to a route by the name given to it in the routes file.
+type RouteName string
+
 type RedirectToActionResult struct {
-	val interface{}
+	val  interface{}
+	args []interface{}
 }
 
 func (r *RedirectToActionResult) Apply(req *Request, resp *Response) {
-	url, err := getRedirectUrl(r.val)
+	url, err := getRedirectUrl(r.val, r.args...)
 	if err != nil {
 		ERROR.Println("Couldn't resolve redirect:", err.Error())
 		ErrorResult{Error: err}.Apply(req, resp)
@@ -375,36 +378,30 @@ func (r *RedirectToActionResult) Apply(req *Request, resp *Response) {
 	resp.WriteHeader(http.StatusFound, "")
 }
 
-func getRedirectUrl(item interface{}) (string, error) {
+func getRedirectUrl(item interface{}, args ...interface{}) (string, error) {
 	// Handle strings
 	if url, ok := item.(string); ok {
 		return url, nil
 	}
 
-	// Handle funcs
-	val := reflect.ValueOf(item)
-	typ := reflect.TypeOf(item)
-	if typ.Kind() == reflect.Func && typ.NumIn() > 0 {
-		// Get the Controller Method
-		recvType := typ.In(0)
-		method := findMethod(recvType, val)
-		if method == nil {
-			return "", errors.New("couldn't find method")
-		}
-
-		// Construct the action string (e.g. "Controller.Method")
-		if recvType.Kind() == reflect.Ptr {
-			recvType = recvType.Elem()
-		}
-		action := recvType.Name() + "." + method.Name
-		actionDef := MainRouter.Reverse(action, make(map[string]string))
-		if actionDef == nil {
-			return "", errors.New("no route for action " + action)
+	// Handle named routes
+	if name, ok := item.(RouteName); ok {
+		actionDef, err := reverseAction(string(name), args)
+		if err != nil {
+			return "", err
 		}
-
 		return actionDef.String(), nil
 	}
 
-	// Out of guesses
-	return "", errors.New("didn't recognize type: " + typ.String())
+	// Handle funcs
+	action, err := actionName(item)
+	if err != nil {
+		return "", err
+	}
+	actionDef := MainRouter.Reverse(action, make(map[string]string))
+	if actionDef == nil {
+		return "", errors.New("no route for action " + action)
+	}
+
+	return actionDef.String(), nil
 }
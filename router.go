@@ -2,24 +2,32 @@ package mars
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"github.com/roblillack/mars/internal/pathtree"
+	"github.com/roblillack/mars/internal/watcher"
 )
 
 type Route struct {
-	Method         string   // e.g. GET
-	Path           string   // e.g. /app/:id
-	Action         string   // e.g. "Application.ShowApp", "404"
-	ControllerName string   // e.g. "Application", ""
-	MethodName     string   // e.g. "ShowApp", ""
-	FixedParams    []string // e.g. "arg1","arg2","arg3" (CSV formatting)
+	Method         string            // e.g. GET
+	Name           string            // e.g. hotel, used for reverse routing
+	Attributes     map[string]string // e.g. {auth: admin, cache: 60s}
+	Host           string            // e.g. "api.example.com", ":tenant.example.com", ""
+	Path           string            // e.g. /app/:id<int>
+	Action         string            // e.g. "Application.ShowApp", "404"
+	ControllerName string            // e.g. "Application", ""
+	MethodName     string            // e.g. "ShowApp", ""
+	FixedParams    []string          // e.g. "arg1","arg2","arg3" (CSV formatting)
+	Constraints    map[string]string // e.g. {"id": "int"}
 
 	routesPath string // e.g. /Users/robfig/gocode/src/myapp/conf/routes
 	line       int    // e.g. 3
@@ -31,9 +39,13 @@ type RouteMatch struct {
 	MethodName     string // e.g. ShowApp
 	FixedParams    []string
 	Params         map[string][]string // e.g. {id: 123}
+	Route          *Route              // the route that matched
+	Attributes     map[string]string   // e.g. {auth: admin, cache: 60s}
+	Redirect       string              // if set, the request should be redirected permanently to this path
 }
 
-// Prepares the route to be used in matching.
+// Prepares the route to be used in matching. The path may be preceded by a
+// host pattern to restrict the route to, e.g. "api.example.com/users".
 func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *Route) {
 
 	// Handle fixed arguments
@@ -45,8 +57,10 @@ func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *
 		ERROR.Printf("Invalid fixed parameters (%v): for string '%v'", err.Error(), fixedArgs)
 	}
 
+	host, path := splitHost(path)
 	r = &Route{
 		Method:      strings.ToUpper(method),
+		Host:        lowerHost(host),
 		Path:        path,
 		Action:      action,
 		FixedParams: fargs,
@@ -66,24 +80,174 @@ func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *
 		r.MethodName = actionSplit[1]
 	}
 
+	r.Constraints = routeConstraints(append(hostLabels(r.Host), strings.Split(r.Path, "/")...))
+
 	return
 }
 
+// Source returns the file and line the route has been defined at. For routes
+// added using the Go API, this is the location of the calling code.
+func (r *Route) Source() (string, int) {
+	return r.routesPath, r.line + 1
+}
+
+// splitHost separates a host pattern from the path following it, e.g.
+// "api.example.com/users" => "api.example.com", "/users"
+func splitHost(path string) (string, string) {
+	if strings.HasPrefix(path, "/") {
+		return "", path
+	}
+	if idx := strings.IndexByte(path, '/'); idx > 0 {
+		return path[:idx], path[idx:]
+	}
+	return "", path
+}
+
+// hostLabels splits a host (pattern) into its labels, e.g.
+// ":tenant<[a-z.]+>.example.com" => ":tenant<[a-z.]+>", "example", "com"
+func hostLabels(host string) []string {
+	if host == "" {
+		return nil
+	}
+
+	var labels []string
+	start, brackets := 0, 0
+	for i := 0; i < len(host); i++ {
+		switch host[i] {
+		case '<':
+			brackets++
+		case '>':
+			brackets--
+		case '.':
+			if brackets == 0 {
+				labels = append(labels, host[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(labels, host[start:])
+}
+
+// lowerHost returns the given host pattern with its static labels in lower
+// case, as request hosts are matched in lower case. Wildcards and their
+// constraints are kept as given.
+func lowerHost(host string) string {
+	labels := hostLabels(host)
+	for i, label := range labels {
+		if !strings.HasPrefix(label, ":") {
+			labels[i] = strings.ToLower(label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// requestHost returns the host name of the given request in lower case and
+// without the port, or the empty string if it cannot be used for routing.
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if host == "" && req.URL != nil {
+		host = req.URL.Host
+	}
+	if idx := strings.LastIndexByte(host, ':'); idx != -1 && !strings.Contains(host[idx:], "]") {
+		host = host[:idx]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if strings.ContainsAny(host, "/@") || strings.HasPrefix(host, ".") || strings.Contains(host, "..") {
+		return ""
+	}
+	return host
+}
+
+// treeKey calculates the path used for looking up a route in the routing tree.
+// The labels of the host are prepended as separate path elements and split
+// from the actual path by an "@" element, e.g. /GET/api/example/com/@/users
+func treeKey(method, host, path string) string {
+	if host == "" {
+		return "/" + method + path
+	}
+	return "/" + method + "/" + strings.Join(hostLabels(host), "/") + "/@" + path
+}
+
+// routeConstraints collects the constraints of all wildcards in the given path
+// elements, e.g. /app/:id<int>/:slug<[a-z]+> => {"id": "int", "slug": "[a-z]+"}
+func routeConstraints(elements []string) map[string]string {
+	var constraints map[string]string
+	for _, el := range elements {
+		if el == "" || (el[0] != ':' && el[0] != '*') {
+			continue
+		}
+		el, constraint := pathtree.SplitConstraint(el)
+		if constraint == "" {
+			continue
+		}
+		if dotIdx := strings.IndexRune(el[1:], '.'); dotIdx > 0 {
+			el = el[0 : dotIdx+1]
+		}
+		if constraints == nil {
+			constraints = make(map[string]string)
+		}
+		constraints[el[1:]] = constraint
+	}
+	return constraints
+}
+
 func (r Route) TreePath() string {
 	method := r.Method
 	if method == "*" {
 		method = ":METHOD"
 	}
-	return "/" + method + r.Path
+	return treeKey(method, r.Host, r.Path)
 }
 
 type Router struct {
-	Routes []*Route
-	Tree   *pathtree.Node
-	path   string // path to the routes file
+	Routes   []*Route
+	Tree     *pathtree.Node
+	hostTree *pathtree.Node // routes restricted to a host, which take precedence.
+	path     string         // path to the routes file
+	added    []*Route       // routes added using the Go API, these take precedence over the routes file
+
+	watcher *watcher.Watcher // if set, all routes files are watched for changes.
+	watched map[string]bool  // routes files already registered with the watcher.
+
+	// Policies for requests differing from the matched route by a trailing
+	// slash, or matching a route only after ignoring the case of the path.
+	// As the routing tree ignores trailing slashes, NewRouter sets
+	// TrailingSlash to PathLenient.
+	TrailingSlash   PathPolicy
+	CaseInsensitive PathPolicy
+}
+
+// PathPolicy defines how requests are handled, which match a route only after
+// normalizing their path.
+type PathPolicy int
+
+const (
+	PathStrict   PathPolicy = iota // Do not match the route at all.
+	PathLenient                    // Route the request as if it matched exactly.
+	PathRedirect                   // Redirect GET and HEAD requests permanently to the route's path, route others leniently.
+)
+
+// parsePathPolicy reads a policy from the given config key, which may either
+// be set to "redirect", the given strict or the given lenient value.
+func parsePathPolicy(key, strict, lenient string, defaultPolicy PathPolicy) PathPolicy {
+	switch value := Config.StringDefault(key, ""); value {
+	case "":
+		return defaultPolicy
+	case strict:
+		return PathStrict
+	case lenient:
+		return PathLenient
+	case "redirect":
+		return PathRedirect
+	default:
+		ERROR.Fatalf("Invalid value for %s: %s (expected %s, %s or redirect)", key, value, strict, lenient)
+		return PathStrict
+	}
 }
 
-var notFound = &RouteMatch{Action: "404"}
+// routableMethods are the HTTP methods checked when determining which methods
+// are allowed for a given path.
+var routableMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 
 func (router *Router) Route(req *http.Request) *RouteMatch {
 	// Override method if set in header
@@ -95,10 +259,178 @@ func (router *Router) Route(req *http.Request) *RouteMatch {
 		return nil
 	}
 
-	leaf, expansions := router.Tree.Find(fmt.Sprintf("/%s%s", req.Method, req.URL.Path))
+	return router.routeLeniently(req.Method, requestHost(req), req.URL.Path)
+}
+
+// routeLeniently looks up the route for the given request properties. If the
+// router's policies allow for it, the case of the path is ignored if there is
+// no exact match. Depending on the policy, differences regarding a trailing
+// slash are ignored or not accepted at all. If the request shall be redirected
+// to the canonical path, it is set in the resulting RouteMatch.
+func (router *Router) routeLeniently(method, host, path string) *RouteMatch {
+	redirect := false
+	match := router.route(method, host, path)
+	if match == nil && router.CaseInsensitive != PathStrict {
+		for _, candidate := range router.caseCandidates(path) {
+			if match = router.route(method, host, candidate); match != nil {
+				redirect = router.CaseInsensitive == PathRedirect
+				path = candidate
+				break
+			}
+		}
+	}
+	if match == nil {
+		return nil
+	}
+
+	// The routing tree itself ignores trailing slashes.
+	if router.TrailingSlash != PathLenient && match.Route != nil {
+		routePath := match.Route.Path
+		star := strings.LastIndexByte(routePath, '*') > strings.LastIndexByte(routePath, '/')
+		if want := strings.HasSuffix(routePath, "/"); !star && path != "/" && strings.HasSuffix(path, "/") != want {
+			if router.TrailingSlash == PathStrict {
+				return nil
+			}
+			if want {
+				path += "/"
+			} else {
+				path = strings.TrimSuffix(path, "/")
+			}
+			redirect = true
+		}
+	}
+
+	if redirect && (method == "GET" || method == "HEAD") {
+		// Paths like "//example.com/" would be taken as URLs of another host.
+		if !safeRedirectPath(path) {
+			return nil
+		}
+		match.Redirect = path
+	}
+	return match
+}
+
+// safeRedirectPath checks whether the given path may be used as the location
+// of a redirect, i.e. it is absolute, does not contain empty segments and
+// cannot be mistaken for a network-path reference like "//example.com".
+func safeRedirectPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.Contains(path, "//") &&
+		!strings.HasPrefix(path, "/\\")
+}
+
+// caseCandidates returns the paths of all routes matching the given path when
+// ignoring case. Wildcard values are kept as given.
+func (router *Router) caseCandidates(path string) []string {
+	var candidates []string
+	seen := map[string]bool{path: true}
+	for _, route := range router.Routes {
+		if candidate, ok := foldPath(route.Path, path); ok && !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// foldPath returns the given path with the case of its static elements
+// changed to the ones of the route path, if it matches case-insensitively.
+func foldPath(routePath, path string) (string, bool) {
+	// Trailing slashes are handled by the routing tree.
+	slash := ""
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		path, slash = path[:len(path)-1], "/"
+	}
+	if len(routePath) > 1 {
+		routePath = strings.TrimSuffix(routePath, "/")
+	}
+
+	routeElements, elements := strings.Split(routePath, "/"), strings.Split(path, "/")
+	for i, el := range routeElements {
+		if i >= len(elements) {
+			return "", false
+		}
+		switch {
+		case el != "" && el[0] == '*':
+			return strings.Join(elements, "/") + slash, true
+		case el != "" && el[0] == ':':
+			// Fix up the case of a static extension like in :id.json
+			el, _ = pathtree.SplitConstraint(el)
+			if dotIdx := strings.IndexRune(el[1:], '.'); dotIdx > 0 && el[dotIdx+2] != ':' {
+				ext := el[dotIdx+1:]
+				if len(elements[i]) <= len(ext) || !strings.EqualFold(elements[i][len(elements[i])-len(ext):], ext) {
+					return "", false
+				}
+				elements[i] = elements[i][:len(elements[i])-len(ext)] + ext
+			}
+		case strings.EqualFold(el, elements[i]):
+			elements[i] = el
+		default:
+			return "", false
+		}
+	}
+	if len(routeElements) != len(elements) {
+		return "", false
+	}
+	return strings.Join(elements, "/") + slash, true
+}
+
+// AllowedMethods returns the HTTP methods for which the given request's host
+// and path resolve to an existing action, applying the router's policies
+// regarding trailing slashes and case. As Mars answers OPTIONS requests
+// automatically, OPTIONS is always part of the result unless the path cannot
+// be routed at all.
+func (router *Router) AllowedMethods(req *http.Request) []string {
+	if router == nil {
+		return nil
+	}
+
+	host := requestHost(req)
+	var methods []string
+	for _, method := range routableMethods {
+		route := router.routeLeniently(method, host, req.URL.Path)
+		if route == nil || route.Action == "404" {
+			continue
+		}
+		if ct, ok := controllers[strings.ToLower(route.ControllerName)]; !ok || ct.Method(route.MethodName) == nil {
+			continue
+		}
+		methods = append(methods, method)
+	}
+
+	if len(methods) > 0 && methods[len(methods)-1] != "OPTIONS" {
+		methods = append(methods, "OPTIONS")
+	}
+
+	return methods
+}
+
+// lookupKey returns the key for looking up a request in the routing tree, like
+// treeKey does for routes. Request hosts do not contain constraints, so all
+// dots separate labels.
+func lookupKey(method, host, path string) string {
+	if host == "" {
+		return "/" + method + path
+	}
+	return "/" + method + "/" + strings.ReplaceAll(host, ".", "/") + "/@" + path
+}
+
+// route looks up the route for the given request properties. Routes
+// restricted to the requested host take precedence over the other ones.
+func (router *Router) route(method, host, path string) *RouteMatch {
+	if host != "" && router.hostTree != nil {
+		if leaf, expansions := router.hostTree.Find(lookupKey(method, host, path)); leaf != nil {
+			return newRouteMatch(leaf, expansions)
+		}
+	}
+
+	leaf, expansions := router.Tree.Find(lookupKey(method, "", path))
 	if leaf == nil {
 		return nil
 	}
+	return newRouteMatch(leaf, expansions)
+}
+
+func newRouteMatch(leaf *pathtree.Leaf, expansions []string) *RouteMatch {
 	route := leaf.Value.(*Route)
 
 	// Create a map of the route parameters.
@@ -116,7 +448,7 @@ func (router *Router) Route(req *http.Request) *RouteMatch {
 
 	// Special handling for explicit 404's.
 	if route.Action == "404" {
-		return notFound
+		return &RouteMatch{Action: "404", Route: route}
 	}
 
 	// If the action is variablized, replace into it with the captured args.
@@ -133,34 +465,187 @@ func (router *Router) Route(req *http.Request) *RouteMatch {
 		MethodName:     methodName,
 		Params:         params,
 		FixedParams:    route.FixedParams,
+		Route:          route,
+		Attributes:     route.Attributes,
+	}
+}
+
+// LoadRoutes reads the given routes file, including the files it includes,
+// into a new router. Contrary to Refresh, the actions are not validated, which
+// allows inspecting the routes of an application without running it.
+func LoadRoutes(routesPath string) (*Router, error) {
+	router := NewRouter(routesPath)
+	routes, err := parseRoutesFile(routesPath, "", false, &routesFiles{})
+	if err != nil {
+		return nil, err
 	}
+	router.Routes = routes
+
+	if err := router.updateTree(); err != nil {
+		return nil, err
+	}
+
+	return router, nil
 }
 
 // Refresh re-reads the routes file and re-calculates the routing table.
 // Returns an error if a specified action could not be found.
 func (router *Router) Refresh() error {
-	if r, err := parseRoutesFile(router.path, "", true); err != nil {
+	files := &routesFiles{}
+	routes, err := parseRoutesFile(router.path, "", true, files)
+
+	// Watch included files even if parsing failed, so that fixing them
+	// triggers another refresh.
+	if err := router.watchFiles(files.all); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	router.Routes = append(append(make([]*Route, 0, len(router.added)+len(routes)), router.added...), routes...)
+
+	if err := router.updateTree(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pendingRoutes holds the routes registered using AddRoute before the main
+// router has been set up.
+var pendingRoutes []func(router *Router) error
+
+// AddRoute registers a route in addition to the ones read from the routes file.
+// This allows packages to ship their own routes, e.g.:
+//
+//	func init() {
+//		mars.AddRoute("GET", "/health", (*Health).Check)
+//	}
+//
+// The action can either be given as a method reference or as a string like
+// "Health.Check". Fixed params work like the ones given in the routes file.
+// Routes added this way take precedence over the routes file and survive
+// refreshing it. They can be added before Mars has been set up, in which case
+// they will be validated during setup. AddRoute panics on invalid routes.
+func AddRoute(method, path string, action interface{}, fixedArgs ...string) {
+	_, file, line, _ := runtime.Caller(1)
+	add := func(router *Router) error {
+		return router.add(method, path, action, fixedArgs, file, line)
+	}
+
+	if MainRouter == nil {
+		pendingRoutes = append(pendingRoutes, add)
+		return
+	}
+	if err := add(MainRouter); err != nil {
+		panic(err)
+	}
+}
+
+// Add adds a route to the router in addition to the ones read from the routes
+// file. See AddRoute for details.
+func (router *Router) Add(method, path string, action interface{}, fixedArgs ...string) error {
+	_, file, line, _ := runtime.Caller(1)
+	return router.add(method, path, action, fixedArgs, file, line)
+}
+
+func (router *Router) add(method, path string, action interface{}, fixedArgs []string, file string, line int) error {
+	actionName, err := actionName(action)
+	if err != nil {
 		return err
-	} else {
-		router.Routes = r
+	}
+	method = strings.ToUpper(method)
+	if !routeMethodPattern.MatchString(method) {
+		return fmt.Errorf("mars/router: invalid method %s for route %s", method, path)
+	}
+	host, path := splitHost(path)
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("mars/router: absolute URL required for route %s", path)
+	}
+
+	// The source location of the caller is used instead of the routes file
+	// when reporting errors.
+	route := NewRoute(method, host+AppRoot+path, actionName, "", file, line-1)
+	route.FixedParams = fixedArgs
+	if err := validateRoute(route); err != nil {
+		return routeError(err, file, "", line-1)
 	}
 
+	oldAdded, oldRoutes := router.added, router.Routes
+	fileRoutes := router.Routes
+	if len(fileRoutes) >= len(router.added) {
+		fileRoutes = fileRoutes[len(router.added):]
+	}
+	router.added = append(router.added, route)
+	router.Routes = append(append(make([]*Route, 0, len(router.added)+len(fileRoutes)), router.added...), fileRoutes...)
+
+	// Leave the router untouched, if the route conflicts with existing ones.
 	if err := router.updateTree(); err != nil {
+		router.added, router.Routes = oldAdded, oldRoutes
+		router.updateTree()
 		return err
 	}
 
 	return nil
 }
 
+// watchFiles registers the given routes files with the router's watcher, so
+// that changes to included routes files are picked up, too.
+func (router *Router) watchFiles(files []string) error {
+	if router.watcher == nil {
+		return nil
+	}
+
+	var unwatched []string
+	for _, f := range files {
+		if !router.watched[f] {
+			unwatched = append(unwatched, f)
+		}
+	}
+	if len(unwatched) == 0 {
+		return nil
+	}
+
+	if err := router.watcher.Listen(router, unwatched...); err != nil {
+		return err
+	}
+	if router.watched == nil {
+		router.watched = make(map[string]bool)
+	}
+	for _, f := range unwatched {
+		router.watched[f] = true
+	}
+
+	return nil
+}
+
 func (router *Router) updateTree() *Error {
 	router.Tree = pathtree.New()
+	router.hostTree = nil
+	names := make(map[string]*Route)
 
 	for _, route := range router.Routes {
-		err := router.Tree.Add(route.TreePath(), route)
+		if route.Name != "" {
+			if other, ok := names[route.Name]; ok {
+				return routeError(fmt.Errorf("Duplicate route name %s, already used for %s %s",
+					route.Name, other.Method, other.Path), route.routesPath, "", route.line)
+			}
+			names[route.Name] = route
+		}
+
+		tree := router.Tree
+		if route.Host != "" {
+			if router.hostTree == nil {
+				router.hostTree = pathtree.New()
+			}
+			tree = router.hostTree
+		}
+
+		err := tree.Add(route.TreePath(), route)
 
 		// Allow GETs to respond to HEAD requests.
 		if err == nil && route.Method == "GET" {
-			err = router.Tree.Add("/HEAD"+route.Path, route)
+			err = tree.Add(treeKey("HEAD", route.Host, route.Path), route)
 		}
 
 		// Error adding a route to the pathtree.
@@ -172,8 +657,15 @@ func (router *Router) updateTree() *Error {
 	return nil
 }
 
+// routesFiles keeps track of the routes files read while parsing, to detect
+// include cycles and to know which files to watch for changes.
+type routesFiles struct {
+	stack []string // the chain of files currently being parsed
+	all   []string // every file that has been read
+}
+
 // parseRoutesFile reads the given routes file and returns the contained routes.
-func parseRoutesFile(routesPath, joinedPath string, validate bool) ([]*Route, *Error) {
+func parseRoutesFile(routesPath, joinedPath string, validate bool, files *routesFiles) ([]*Route, *Error) {
 	contentBytes, err := ioutil.ReadFile(routesPath)
 	if err != nil {
 		return nil, &Error{
@@ -181,14 +673,22 @@ func parseRoutesFile(routesPath, joinedPath string, validate bool) ([]*Route, *E
 			Description: err.Error(),
 		}
 	}
+	files.all = append(files.all, routesPath)
 
-	return parseRoutes(routesPath, joinedPath, string(contentBytes), validate)
+	return parseRoutesContent(routesPath, joinedPath, string(contentBytes), validate, files)
 }
 
 // parseRoutes reads the content of a routes file into the routing table.
 func parseRoutes(routesFilePath, joinedPath, content string, validate bool) ([]*Route, *Error) {
+	return parseRoutesContent(routesFilePath, joinedPath, content, validate, &routesFiles{})
+}
+
+func parseRoutesContent(routesFilePath, joinedPath, content string, validate bool, files *routesFiles) ([]*Route, *Error) {
 	var routes []*Route
 
+	files.stack = append(files.stack, routesFilePath)
+	defer func() { files.stack = files.stack[:len(files.stack)-1] }()
+
 	// For each line..
 	for n, line := range strings.Split(content, "\n") {
 		line = strings.TrimSpace(line)
@@ -196,8 +696,18 @@ func parseRoutes(routesFilePath, joinedPath, content string, validate bool) ([]*
 			continue
 		}
 
+		// Another routes file, optionally mounted below a path prefix.
+		if fields := strings.Fields(line); fields[0] == "include" {
+			included, err := parseIncludeLine(fields, routesFilePath, joinedPath, validate, files)
+			if err != nil {
+				return nil, routeError(err, routesFilePath, content, n)
+			}
+			routes = append(routes, included...)
+			continue
+		}
+
 		// A single route
-		method, path, action, fixedArgs, found := parseRouteLine(line)
+		method, path, action, fixedArgs, name, attributes, found := parseRouteLine(line)
 		if !found {
 			continue
 		}
@@ -205,12 +715,15 @@ func parseRoutes(routesFilePath, joinedPath, content string, validate bool) ([]*
 		// this will avoid accidental double forward slashes in a route.
 		// this also avoids pathtree freaking out and causing a runtime panic
 		// because of the double slashes
+		host, path := splitHost(path)
 		if strings.HasSuffix(joinedPath, "/") && strings.HasPrefix(path, "/") {
 			joinedPath = joinedPath[0 : len(joinedPath)-1]
 		}
-		path = strings.Join([]string{AppRoot, joinedPath, path}, "")
+		path = strings.Join([]string{host, AppRoot, joinedPath, path}, "")
 
 		route := NewRoute(method, path, action, fixedArgs, routesFilePath, n)
+		route.Name = name
+		route.Attributes = attributes
 		routes = append(routes, route)
 
 		if validate {
@@ -223,8 +736,52 @@ func parseRoutes(routesFilePath, joinedPath, content string, validate bool) ([]*
 	return routes, nil
 }
 
+// parseIncludeLine handles a line like `include admin.routes /admin`, which
+// adds all routes of the given file (relative to the including one) with the
+// optional path prefix prepended to each of their paths.
+func parseIncludeLine(fields []string, routesFilePath, joinedPath string, validate bool, files *routesFiles) ([]*Route, error) {
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, errors.New("Expected include directive of the form: include <file> [<prefix>]")
+	}
+
+	prefix := ""
+	if len(fields) == 3 {
+		if prefix = fields[2]; prefix[0] != '/' {
+			return nil, fmt.Errorf("Include prefix must begin with /: %s", prefix)
+		}
+	}
+
+	includePath := filepath.FromSlash(fields[1])
+	if !filepath.IsAbs(includePath) {
+		includePath = filepath.Join(filepath.Dir(routesFilePath), includePath)
+	}
+	for _, f := range files.stack {
+		if f == includePath {
+			return nil, fmt.Errorf("Routes file %s includes itself", fields[1])
+		}
+	}
+
+	contentBytes, err := ioutil.ReadFile(includePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to include routes file: %s", err)
+	}
+	files.all = append(files.all, includePath)
+
+	routes, marsErr := parseRoutesContent(includePath, strings.TrimSuffix(joinedPath, "/")+prefix, string(contentBytes), validate, files)
+	if marsErr != nil {
+		return nil, marsErr
+	}
+	return routes, nil
+}
+
 // validateRoute checks that every specified action exists.
 func validateRoute(route *Route) error {
+	for _, label := range hostLabels(route.Host) {
+		if label == "" {
+			return fmt.Errorf("Empty label in host %s", route.Host)
+		}
+	}
+
 	// Skip 404s
 	if route.Action == "404" {
 		return nil
@@ -273,31 +830,60 @@ func routeError(err error, routesPath, content string, n int) *Error {
 	}
 }
 
+const routeMethods = "GET|POST|PUT|DELETE|PATCH|OPTIONS|HEAD|WS|\\*"
+
+var routeMethodPattern = regexp.MustCompile("^(" + routeMethods + ")$")
+
 // Groups:
 // 1: method
 // 4: path
 // 5: action
 // 6: fixedargs
 var routePattern *regexp.Regexp = regexp.MustCompile(
-	"(?i)^(GET|POST|PUT|DELETE|PATCH|OPTIONS|HEAD|WS|\\*)" +
-		"[(]?([^)]*)(\\))?[ \t]+" +
-		"(.*/[^ \t]*)[ \t]+([^ \t(]+)" +
-		`\(?([^)]*)\)?[ \t]*$`)
-
-func parseRouteLine(line string) (method, path, action, fixedArgs string, found bool) {
+	"(?i)^(" + routeMethods + ")" +
+		"(?:[(]([^)]*)(\\)))?[ \t]+" +
+		"([^ \t]*/[^ \t]*)[ \t]+([^ \t(]+)" +
+		`(?:\(([^)]*)\))?` +
+		`(?:[ \t]+as[ \t]+([a-zA-Z0-9_-]+))?` +
+		`(?:[ \t]+\{([^}]*)\})?[ \t]*$`)
+
+func parseRouteLine(line string) (method, path, action, fixedArgs, name string, attributes map[string]string, found bool) {
 	var matches []string = routePattern.FindStringSubmatch(line)
 	if matches == nil {
 		return
 	}
-	method, path, action, fixedArgs = matches[1], matches[4], matches[5], matches[6]
+	method, path, action, fixedArgs, name = matches[1], matches[4], matches[5], matches[6], matches[7]
+	attributes = parseRouteAttributes(matches[8])
 	found = true
 	return
 }
 
+// parseRouteAttributes reads an attribute block like {auth=admin cache=60s api},
+// which contains key/value pairs or simple tags, separated by spaces or commas.
+// Tags are stored using an empty value.
+func parseRouteAttributes(block string) map[string]string {
+	fields := strings.FieldsFunc(block, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+
+	attributes := make(map[string]string, len(fields))
+	for _, field := range fields {
+		key, value, _ := strings.Cut(field, "=")
+		if key != "" {
+			attributes[key] = value
+		}
+	}
+	return attributes
+}
+
 func NewRouter(routesPath string) *Router {
 	return &Router{
-		Tree: pathtree.New(),
-		path: routesPath,
+		Tree:          pathtree.New(),
+		path:          routesPath,
+		TrailingSlash: PathLenient,
 	}
 }
 
@@ -356,6 +942,11 @@ func encodePathSegment(s string) string {
 }
 
 func (router *Router) Reverse(action string, argValues map[string]string) *ActionDefinition {
+	// Anything not looking like Controller.Action refers to a named route.
+	if !strings.Contains(action, ".") {
+		return router.ReverseName(action, argValues)
+	}
+
 	actionSplit := strings.Split(action, ".")
 	if len(actionSplit) != 2 {
 		ERROR.Print("mars/router: reverse router got invalid action ", action)
@@ -383,72 +974,141 @@ func (router *Router) Reverse(action string, argValues map[string]string) *Actio
 			argValues[route.MethodName[1:]] = methodName
 		}
 
-		// Build up the URL.
-		var (
-			queryValues  = make(url.Values)
-			pathElements = strings.Split(route.Path, "/")
-		)
-		extension := ""
-		for i, el := range pathElements {
-			if el == "" || (el[0] != ':' && el[0] != '*') {
-				continue
-			}
+		return reverseRoute(route, action, argValues)
+	}
+	ERROR.Println("Failed to find reverse route:", action, argValues)
+	return nil
+}
 
-			if dotIdx := strings.IndexRune(el[1:], '.'); dotIdx > 0 {
-				extension = el[1+dotIdx:]
-				el = el[0 : dotIdx+1]
-			}
+// ReverseName returns the URL of the route with the given name, using the
+// given arguments to fill in its wildcards. Wildcard controller or method
+// names of the route need to be part of the arguments, too.
+func (router *Router) ReverseName(name string, argValues map[string]string) *ActionDefinition {
+	route := router.namedRoute(name)
+	if route == nil {
+		ERROR.Println("Failed to find reverse route named:", name, argValues)
+		return nil
+	}
 
-			val, ok := argValues[el[1:]]
-			if !ok {
-				val = "<nil>"
-				ERROR.Print("mars/router: reverse route missing route arg ", el[1:])
-			}
-			if el[0] == '*' {
-				pathElements[i] = (&url.URL{Path: val}).RequestURI() + extension
-			} else {
-				pathElements[i] = encodePathSegment(val) + extension
-			}
-			delete(argValues, el[1:])
-			continue
+	action := route.Action
+	if route.ControllerName != "" && route.MethodName != "" {
+		controllerName, methodName := route.ControllerName, route.MethodName
+		if controllerName[0] == ':' {
+			controllerName = argValues[controllerName[1:]]
+		}
+		if methodName[0] == ':' {
+			methodName = argValues[methodName[1:]]
+		}
+		action = controllerName + "." + methodName
+	}
+
+	return reverseRoute(route, action, argValues)
+}
+
+// namedRoute returns the route with the given name, or nil.
+func (router *Router) namedRoute(name string) *Route {
+	for _, route := range router.Routes {
+		if route.Name == name {
+			return route
 		}
+	}
+	return nil
+}
 
-		// Add any args that were not inserted into the path into the query string.
-		for k, v := range argValues {
-			queryValues.Set(k, v)
+// reverseRoute builds the URL of the given route using the given arguments.
+// Arguments not used as part of the host or path end up in the query string.
+func reverseRoute(route *Route, action string, argValues map[string]string) *ActionDefinition {
+	// Fill in the host, if the route is restricted to one.
+	labels := hostLabels(route.Host)
+	for i, label := range labels {
+		if label == "" || label[0] != ':' {
+			continue
+		}
+		label, _ = pathtree.SplitConstraint(label)
+		val, ok := argValues[label[1:]]
+		if !ok {
+			val = "<nil>"
+			ERROR.Print("mars/router: reverse route missing host arg ", label[1:])
+		}
+		labels[i] = val
+		delete(argValues, label[1:])
+	}
+	host := strings.Join(labels, ".")
+
+	// Build up the URL.
+	var (
+		queryValues  = make(url.Values)
+		pathElements = strings.Split(route.Path, "/")
+	)
+	extension := ""
+	for i, el := range pathElements {
+		if el == "" || (el[0] != ':' && el[0] != '*') {
+			continue
 		}
 
-		// Calculate the final URL and Method
-		url := strings.Join(pathElements, "/")
-		if len(queryValues) > 0 {
-			url += "?" + queryValues.Encode()
+		el, _ = pathtree.SplitConstraint(el)
+
+		if dotIdx := strings.IndexRune(el[1:], '.'); dotIdx > 0 {
+			extension = el[1+dotIdx:]
+			el = el[0 : dotIdx+1]
 		}
 
-		method := route.Method
-		star := false
-		if route.Method == "*" {
-			method = "GET"
-			star = true
+		val, ok := argValues[el[1:]]
+		if !ok {
+			val = "<nil>"
+			ERROR.Print("mars/router: reverse route missing route arg ", el[1:])
+		}
+		if el[0] == '*' {
+			pathElements[i] = (&url.URL{Path: val}).RequestURI() + extension
+		} else {
+			pathElements[i] = encodePathSegment(val) + extension
 		}
+		delete(argValues, el[1:])
+		continue
+	}
 
-		return &ActionDefinition{
-			Url:    url,
-			Method: method,
-			Star:   star,
-			Action: action,
-			Args:   argValues,
-			Host:   "TODO",
+	// Add any args that were not inserted into the path into the query string.
+	for k, v := range argValues {
+		queryValues.Set(k, v)
+	}
+
+	// Calculate the final URL and Method
+	url := strings.Join(pathElements, "/")
+	if len(queryValues) > 0 {
+		url += "?" + queryValues.Encode()
+	}
+	if host != "" {
+		scheme := "http"
+		if HttpSsl {
+			scheme = "https"
 		}
+		url = scheme + "://" + host + url
+	}
+
+	method := route.Method
+	star := false
+	if route.Method == "*" {
+		method = "GET"
+		star = true
+	}
+
+	return &ActionDefinition{
+		Url:    url,
+		Method: method,
+		Star:   star,
+		Action: action,
+		Args:   argValues,
+		Host:   host,
 	}
-	ERROR.Println("Failed to find reverse route:", action, argValues)
-	return nil
 }
 
 func RouterFilter(c *Controller, fc []Filter) {
 	// Figure out the Controller/Action
 	var route *RouteMatch = MainRouter.Route(c.Request.Request)
 	if route == nil {
-		c.Result = c.NotFound("No matching route found: " + c.Request.RequestURI)
+		if !routeOtherMethods(c) {
+			c.Result = c.NotFound("No matching route found: " + c.Request.RequestURI)
+		}
 		return
 	}
 
@@ -458,14 +1118,28 @@ func RouterFilter(c *Controller, fc []Filter) {
 		return
 	}
 
+	// Redirect to the canonical path, if the request matched only leniently.
+	if route.Redirect != "" {
+		location := route.Redirect
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Response.Status = http.StatusMovedPermanently
+		c.Result = &RedirectToUrlResult{location}
+		return
+	}
+
 	// Set the action.
 	if err := c.SetAction(route.ControllerName, route.MethodName); err != nil {
-		c.Result = c.NotFound(err.Error())
+		if !routeOtherMethods(c) {
+			c.Result = c.NotFound(err.Error())
+		}
 		return
 	}
 
 	// Add the route and fixed params to the Request Params.
 	c.Params.Route = route.Params
+	c.RouteAttributes = route.Attributes
 
 	// Add the fixed parameters mapped by name.
 	// TODO: Pre-calculate this mapping.
@@ -485,6 +1159,36 @@ func RouterFilter(c *Controller, fc []Filter) {
 	fc[0](c, fc[1:])
 }
 
+// routeOtherMethods checks whether the requested path can be routed using
+// other HTTP methods than the one requested. If so, OPTIONS requests are
+// answered with the list of allowed methods, including the CORS headers for
+// preflight requests to actions using CORSFilter, and every other request
+// results in a 405 Method Not Allowed. Returns false, if the path cannot be routed at all.
+func routeOtherMethods(c *Controller) bool {
+	allowed := MainRouter.AllowedMethods(c.Request.Request)
+	if len(allowed) == 0 {
+		return false
+	}
+
+	c.Response.Out.Header().Set("Allow", strings.Join(allowed, ", "))
+	if c.Request.Method == "OPTIONS" {
+		// Answer preflight requests for actions using CORSFilter.
+		if isPreflightRequest(c.Request.Request) && corsEnabledFor(c.Request.Request) {
+			DefaultCORS.answerPreflight(c)
+			return true
+		}
+		c.Response.Status = http.StatusNoContent
+		return true
+	}
+
+	c.Response.Status = http.StatusMethodNotAllowed
+	c.Result = c.RenderError(&Error{
+		Title:       "Method not allowed",
+		Description: "Method " + c.Request.Method + " is not allowed (valid: " + strings.Join(allowed, ", ") + ")",
+	})
+	return true
+}
+
 // Override allowed http methods via form or browser param
 func HttpMethodOverride(c *Controller, fc []Filter) {
 	// An array of HTTP verbs allowed.
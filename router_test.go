@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/roblillack/mars/internal/watcher"
 )
 
 // Data-driven tests that check that a given routes-file line translates into
@@ -47,6 +53,41 @@ var routeTestCases = map[string]*Route{
 		FixedParams: []string{},
 	},
 
+	"GET /hotels/:id Hotels.Show as hotel": {
+		Method:      "GET",
+		Name:        "hotel",
+		Path:        "/hotels/:id",
+		Action:      "Hotels.Show",
+		FixedParams: []string{},
+	},
+
+	`GET /img/:filepath Static.Serve("public/img") as image`: {
+		Method: "GET",
+		Name:   "image",
+		Path:   "/img/:filepath",
+		Action: "Static.Serve",
+		FixedParams: []string{
+			"public/img",
+		},
+	},
+
+	"GET /admin/hotels Admin.Hotels {auth=admin ratelimit=10/s, cache=60s}": {
+		Method:      "GET",
+		Path:        "/admin/hotels",
+		Action:      "Admin.Hotels",
+		FixedParams: []string{},
+		Attributes:  map[string]string{"auth": "admin", "ratelimit": "10/s", "cache": "60s"},
+	},
+
+	`GET /img/:filepath Static.Serve("img") as img {cache=3600 public}`: {
+		Method:      "GET",
+		Name:        "img",
+		Path:        "/img/:filepath",
+		Action:      "Static.Serve",
+		FixedParams: []string{"img"},
+		Attributes:  map[string]string{"cache": "3600", "public": ""},
+	},
+
 	`GET /public/:filepath   Static.Serve("public")`: {
 		Method: "GET",
 		Path:   "/public/:filepath",
@@ -86,6 +127,20 @@ var routeTestCases = map[string]*Route{
 		FixedParams: []string{},
 	},
 
+	"GET /app/:id<int> Application.Show": {
+		Method:      "GET",
+		Path:        "/app/:id<int>",
+		Action:      "Application.Show",
+		FixedParams: []string{},
+	},
+
+	`GET /pages/:slug<[a-z0-9-]+>.html Application.Page`: {
+		Method:      "GET",
+		Path:        "/pages/:slug<[a-z0-9-]+>.html",
+		Action:      "Application.Page",
+		FixedParams: []string{},
+	},
+
 	`GET / Application.Index("Test", "Test2")`: {
 		Method: "GET",
 		Path:   "/",
@@ -100,13 +155,17 @@ var routeTestCases = map[string]*Route{
 // Run the test cases above.
 func TestComputeRoute(t *testing.T) {
 	for routeLine, expected := range routeTestCases {
-		method, path, action, fixedArgs, found := parseRouteLine(routeLine)
+		method, path, action, fixedArgs, name, attributes, found := parseRouteLine(routeLine)
 		if !found {
 			t.Error("Failed to parse route line:", routeLine)
 			continue
 		}
 		actual := NewRoute(method, path, action, fixedArgs, "", 0)
+		actual.Name = name
+		actual.Attributes = attributes
 		eq(t, "Method", actual.Method, expected.Method)
+		eq(t, "Name", actual.Name, expected.Name)
+		eq(t, "Attributes", fmt.Sprint(actual.Attributes), fmt.Sprint(expected.Attributes))
 		eq(t, "Path", actual.Path, expected.Path)
 		eq(t, "Action", actual.Action, expected.Action)
 		if t.Failed() {
@@ -325,6 +384,208 @@ func TestRouteMatches(t *testing.T) {
 	}
 }
 
+func TestRouteParamsOutliveLookup(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_ROUTES, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+
+	first := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/app/123/"}})
+	router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/app/456/"}})
+	eq(t, "id", first.Params["id"][0], "123")
+}
+
+const TEST_CONSTRAINED_ROUTES = `
+GET   /hotels/:id<int>            Hotels.Show
+GET   /hotels/:id<uuid>           Hotels.ShowByUUID
+GET   /hotels/:slug<[a-z0-9-]+>   Hotels.ShowBySlug
+GET   /pages/:name<alpha>.html    Pages.Show
+`
+
+func TestRouteConstraints(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_CONSTRAINED_ROUTES, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+
+	eq(t, "Constraint", router.Routes[0].Constraints["id"], "int")
+	eq(t, "Constraint", router.Routes[2].Constraints["slug"], "[a-z0-9-]+")
+	eq(t, "Constraint", router.Routes[3].Constraints["name"], "alpha")
+
+	for path, expected := range map[string]string{
+		"/hotels/123": "Show",
+		"/hotels/4f1c2a38-8f8e-4b8a-9d64-5a3c1f0e7b21": "ShowByUUID",
+		"/hotels/grand-budapest":                       "ShowBySlug",
+		"/pages/about.html":                            "Show",
+		"/hotels/Grand_Budapest":                       "",
+		"/pages/about-us.html":                         "",
+	} {
+		route := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: path}})
+		if expected == "" {
+			if route != nil {
+				t.Errorf("%s: expected no route, got %s.%s", path, route.ControllerName, route.MethodName)
+			}
+			continue
+		}
+		if route == nil {
+			t.Errorf("%s: no route found", path)
+			continue
+		}
+		eq(t, path, route.MethodName, expected)
+	}
+
+	route := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/pages/about.html"}})
+	eq(t, "name", route.Params["name"][0], "about")
+
+	action := router.Reverse("Hotels.Show", map[string]string{"id": "123"})
+	eq(t, "Url", action.Url, "/hotels/123")
+	action = router.Reverse("Pages.Show", map[string]string{"name": "about"})
+	eq(t, "Url", action.Url, "/pages/about.html")
+}
+
+const TEST_HOST_ROUTES = `
+GET  API.Example.com/users                     Api.Users
+GET  :tenant<[a-z]+>.example.com/              Tenants.Index
+GET  :tenant<[a-z]+>.example.com/users/:id     Tenants.User
+GET  /users                                    Application.Users
+*    /:controller/:action                      :controller.:action
+`
+
+func TestHostRoutes(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_HOST_ROUTES, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+
+	eq(t, "Host", router.Routes[0].Host, "api.example.com")
+	eq(t, "Path", router.Routes[0].Path, "/users")
+	eq(t, "Constraint", router.Routes[1].Constraints["tenant"], "[a-z]+")
+
+	for req, expected := range map[string]string{
+		"api.example.com/users":         "Api.Users",
+		"API.Example.com:9000/users":    "Api.Users",
+		"api.example.com./users":        "Api.Users",
+		"acme.example.com/":             "Tenants.Index",
+		"acme.example.com/users/42":     "Tenants.User",
+		"acme.example.com/users":        "Application.Users",
+		".example.com/users":            "Application.Users",
+		"acme..example.com/users/42":    "users.42",
+		"example.com/users":             "Application.Users",
+		"a.b.example.com/users/42":      "users.42",
+		"acme1.example.com/users/42":    "users.42",
+		"[::1]:9000/users":              "Application.Users",
+		"api.example.com/hotels/show":   "hotels.show",
+		"other.example.org/hotels/show": "hotels.show",
+	} {
+		host, path := splitHost(req)
+		route := router.Route(&http.Request{Method: "GET", Host: host, URL: &url.URL{Path: path}})
+		if route == nil {
+			t.Errorf("%s: no route found", req)
+			continue
+		}
+		eq(t, req, route.ControllerName+"."+route.MethodName, expected)
+	}
+
+	if route := router.Route(&http.Request{Method: "GET", Host: ".example.com", URL: &url.URL{Path: "/"}}); route != nil {
+		t.Errorf("Expected no route for empty tenant, got %s.%s", route.ControllerName, route.MethodName)
+	}
+	if err := validateRoute(NewRoute("GET", "api..example.com/", "404", "", "", 0)); err == nil {
+		t.Error("Expected error for empty host label")
+	}
+
+	route := router.Route(&http.Request{Method: "GET", Host: "acme.example.com", URL: &url.URL{Path: "/users/42"}})
+	eq(t, "tenant", route.Params["tenant"][0], "acme")
+	eq(t, "id", route.Params["id"][0], "42")
+
+	route = router.Route(&http.Request{Method: "HEAD", Host: "api.example.com", URL: &url.URL{Path: "/users"}})
+	eq(t, "HEAD", route != nil && route.MethodName == "Users", true)
+
+	action := router.Reverse("Tenants.User", map[string]string{"tenant": "acme", "id": "42"})
+	eq(t, "Url", action.Url, "http://acme.example.com/users/42")
+	eq(t, "Host", action.Host, "acme.example.com")
+	action = router.Reverse("Api.Users", map[string]string{})
+	eq(t, "Url", action.Url, "http://api.example.com/users")
+	action = router.Reverse("Application.Users", map[string]string{})
+	eq(t, "Url", action.Url, "/users")
+	eq(t, "Host", action.Host, "")
+}
+
+const TEST_NAMED_ROUTES = `
+GET  /hotels/:id               Hotels.Show
+GET  /h/:id                    Hotels.Show as shortHotel
+GET  /:controller/:action      :controller.:action as catchAll
+`
+
+func TestNamedRoutes(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_NAMED_ROUTES, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+
+	action := router.Reverse("Hotels.Show", map[string]string{"id": "3"})
+	eq(t, "Url", action.Url, "/hotels/3")
+	action = router.Reverse("shortHotel", map[string]string{"id": "3"})
+	eq(t, "Url", action.Url, "/h/3")
+	eq(t, "Action", action.Action, "Hotels.Show")
+	action = router.ReverseName("catchAll", map[string]string{"controller": "Hotels", "action": "Index", "page": "2"})
+	eq(t, "Url", action.Url, "/Hotels/Index?page=2")
+	eq(t, "Action", action.Action, "Hotels.Index")
+	eq(t, "Unknown name", router.Reverse("longHotel", map[string]string{}) == nil, true)
+
+	router.Routes, _ = parseRoutes("", "", TEST_NAMED_ROUTES+"GET /hotel/:id Hotels.Show as shortHotel\n", false)
+	err := router.updateTree()
+	if err == nil {
+		t.Fatal("Duplicate route name not detected")
+	}
+	eq(t, "Error", strings.Contains(err.Description, "Duplicate route name shortHotel"), true)
+}
+
+func TestReverseNamedRoutes(t *testing.T) {
+	startFakeBookingApp()
+
+	url, err := ReverseUrl("hotel", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, "Url", string(url), "/hotels/3")
+
+	if _, err := ReverseUrl("motel", 3); err == nil {
+		t.Error("Expected error reversing unknown route name")
+	}
+
+	resp := httptest.NewRecorder()
+	c := NewController(NewRequest(showRequest), NewResponse(resp))
+	c.Redirect(RouteName("hotel"), 3).Apply(c.Request, c.Response)
+	eq(t, "Status", resp.Code, http.StatusFound)
+	eq(t, "Location", resp.Header().Get("Location"), "/hotels/3")
+}
+
+func TestLoadRoutes(t *testing.T) {
+	// Actions are not validated, so no controllers need to be registered.
+	router, err := LoadRoutes(filepath.Join("testdata", "conf", "routes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, "Routes", len(router.Routes), 7)
+
+	route := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/hotels/3"}})
+	if route == nil {
+		t.Fatal("No route found")
+	}
+	eq(t, "Route", route.Route, router.Routes[1])
+	file, line := route.Route.Source()
+	eq(t, "File", file, filepath.Join("testdata", "conf", "routes"))
+	eq(t, "Line", line, 6)
+
+	if _, err := LoadRoutes(filepath.Join("testdata", "conf", "nonexistent")); err == nil {
+		t.Error("Expected error loading nonexistent routes file")
+	}
+}
+
 // Reverse Routing
 
 type ReverseRouteArgs struct {
@@ -464,6 +725,30 @@ func BenchmarkRouter(b *testing.B) {
 	}
 }
 
+func BenchmarkRoute(b *testing.B) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_HOST_ROUTES, false)
+	if err := router.updateTree(); err != nil {
+		b.Fatalf("Error creating router: %s", err)
+	}
+
+	for _, req := range []*http.Request{
+		{Method: "GET", URL: &url.URL{Path: "/users"}},
+		{Method: "GET", URL: &url.URL{Path: "/hotels/show"}},
+		{Method: "GET", Host: "api.example.com", URL: &url.URL{Path: "/users"}},
+		{Method: "GET", Host: "acme.example.com", URL: &url.URL{Path: "/users/42"}},
+	} {
+		b.Run(req.Host+req.URL.Path, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if router.Route(req) == nil {
+					b.Fatal("no route found")
+				}
+			}
+		})
+	}
+}
+
 // The benchmark from github.com/ant0ine/go-urlrouter
 func BenchmarkLargeRouter(b *testing.B) {
 	router := NewRouter("")
@@ -534,6 +819,383 @@ func BenchmarkRouterFilter(b *testing.B) {
 	}
 }
 
+func writeRoutesFile(t *testing.T, name, content string) {
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncludedRoutes(t *testing.T) {
+	dir := t.TempDir()
+	writeRoutesFile(t, filepath.Join(dir, "routes"), `
+GET     /                   Application.Index
+include admin.routes        /admin/
+include api/v1.routes       /api/v1
+GET     /:id                Application.Show
+`)
+	writeRoutesFile(t, filepath.Join(dir, "admin.routes"), `
+GET     /                   Admin.Index
+GET     /users/:id          Admin.ShowUser
+include nested.routes       /nested
+`)
+	writeRoutesFile(t, filepath.Join(dir, "nested.routes"), `
+GET     /deep               Admin.Deep
+`)
+	if err := os.Mkdir(filepath.Join(dir, "api"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeRoutesFile(t, filepath.Join(dir, "api", "v1.routes"), `
+POST    /hotels             Api.CreateHotel
+`)
+
+	routes, err := parseRoutesFile(filepath.Join(dir, "routes"), "", false, &routesFiles{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, r := range routes {
+		paths = append(paths, r.Method+" "+r.Path+" "+r.Action)
+	}
+	eq(t, "Routes", strings.Join(paths, "\n"), strings.Join([]string{
+		"GET / Application.Index",
+		"GET /admin/ Admin.Index",
+		"GET /admin/users/:id Admin.ShowUser",
+		"GET /admin/nested/deep Admin.Deep",
+		"POST /api/v1/hotels Api.CreateHotel",
+		"GET /:id Application.Show",
+	}, "\n"))
+	eq(t, "Routes file", routes[3].routesPath, filepath.Join(dir, "nested.routes"))
+	eq(t, "Routes file line", routes[3].line, 1)
+}
+
+func TestIncludedRoutesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeRoutesFile(t, filepath.Join(dir, "routes"), "# Main routes\ninclude other.routes /other\n")
+	writeRoutesFile(t, filepath.Join(dir, "other.routes"), "\n\nGET /x Nope.Nope\n")
+
+	_, err := parseRoutesFile(filepath.Join(dir, "routes"), "", true, &routesFiles{})
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	eq(t, "Error path", err.Path, filepath.Join(dir, "other.routes"))
+	eq(t, "Error line", err.Line, 3)
+
+	writeRoutesFile(t, filepath.Join(dir, "other.routes"), "include routes\n")
+	_, err = parseRoutesFile(filepath.Join(dir, "routes"), "", false, &routesFiles{})
+	if err == nil {
+		t.Fatal("expected include cycle error")
+	}
+	eq(t, "Error path", err.Path, filepath.Join(dir, "other.routes"))
+	eq(t, "Error line", err.Line, 1)
+
+	for _, line := range []string{"include", "include missing.routes", "include other.routes admin", "include a b c"} {
+		if _, err := parseRoutes(filepath.Join(dir, "routes"), "", line, false); err == nil {
+			t.Errorf("expected error for: %s", line)
+		}
+	}
+}
+
+func TestIncludedRoutesAreWatched(t *testing.T) {
+	dir := t.TempDir()
+	writeRoutesFile(t, filepath.Join(dir, "routes"), "include other.routes /other\n")
+	writeRoutesFile(t, filepath.Join(dir, "other.routes"), "GET /a Hotels.Index\n")
+
+	startFakeBookingApp()
+	w := watcher.New()
+	router := NewRouter(filepath.Join(dir, "routes"))
+	router.watcher = w
+	if err := router.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	eq(t, "Route", router.Routes[0].Path, "/other/a")
+
+	writeRoutesFile(t, filepath.Join(dir, "other.routes"), "GET /b Hotels.Index\n")
+	// Filesystem events arrive asynchronously, so poll until the router has
+	// been refreshed.
+	deadline := time.Now().Add(5 * time.Second)
+	for router.Routes[0].Path != "/other/b" && time.Now().Before(deadline) {
+		if err := w.Notify(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	eq(t, "Route", router.Routes[0].Path, "/other/b")
+}
+
+func TestAddRoute(t *testing.T) {
+	startFakeBookingApp()
+
+	router := NewRouter(filepath.Join("testdata", "conf", "routes"))
+	if err := router.Add("GET", "/health", Hotels.Index); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Add("GET", "/public/img/:filepath", "Static.Serve", "public/img"); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Add("post", "/hotels/:id/book", (*Hotels).Book); err != nil {
+		t.Fatal(err)
+	}
+
+	eq(t, "Routes", len(router.Routes), 10)
+	for req, expected := range map[*http.Request]string{
+		{Method: "GET", URL: &url.URL{Path: "/health"}}:           "Hotels.Index",
+		{Method: "GET", URL: &url.URL{Path: "/hotels/3"}}:         "Hotels.Show",
+		{Method: "POST", URL: &url.URL{Path: "/hotels/3/book"}}:   "Hotels.Book",
+		{Method: "GET", URL: &url.URL{Path: "/public/img/a.png"}}: "Static.Serve",
+		{Method: "GET", URL: &url.URL{Path: "/public/css/a.css"}}: "Static.Serve",
+		{Method: "GET", URL: &url.URL{Path: "/hotels/3/booking"}}: "Hotels.Book",
+	} {
+		route := router.Route(req)
+		if route == nil {
+			t.Errorf("No route found for %s %s", req.Method, req.URL.Path)
+			continue
+		}
+		eq(t, req.URL.Path, route.ControllerName+"."+route.MethodName, expected)
+	}
+
+	route := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/public/img/a.png"}})
+	eq(t, "Fixed params", strings.Join(route.FixedParams, ","), "public/img")
+
+	eq(t, "Reverse", router.Reverse("Hotels.Index", map[string]string{}).Url, "/health")
+	eq(t, "Reverse", router.Reverse("Hotels.Book", map[string]string{"id": "3"}).Url, "/hotels/3/book")
+
+	for _, err := range []error{
+		router.Add("FETCH", "/health", Hotels.Index),
+		router.Add("GET", "health", Hotels.Index),
+		router.Add("GET", "/nope", "Nope.Nope"),
+		router.Add("GET", "/nope", 42),
+		router.Add("GET", "/health", Hotels.Show),
+	} {
+		if err == nil {
+			t.Error("Expected error adding route")
+		}
+	}
+
+	eq(t, "Routes", len(router.Routes), 10)
+
+	err := router.Add("GET", "/nope", "Hotels.Nope")
+	if marsErr, ok := err.(*Error); !ok || !strings.HasSuffix(marsErr.Path, "router_test.go") {
+		t.Errorf("Expected error pointing to the caller, got: %v", err)
+	}
+}
+
+func TestAddRouteBeforeSetup(t *testing.T) {
+	startFakeBookingApp()
+	oldRouter := MainRouter
+	defer func() {
+		MainRouter = oldRouter
+		pendingRoutes = nil
+	}()
+
+	MainRouter = nil
+	AddRoute("GET", "/health", Hotels.Index)
+	eq(t, "Pending routes", len(pendingRoutes), 1)
+
+	SetupRouter()
+	route := MainRouter.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/health"}})
+	if route == nil {
+		t.Fatal("No route found for added route")
+	}
+	eq(t, "Action", route.ControllerName+"."+route.MethodName, "Hotels.Index")
+
+	AddRoute("GET", "/ping", Hotels.Index)
+	eq(t, "Pending routes", len(pendingRoutes), 1)
+	if MainRouter.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/ping"}}) == nil {
+		t.Fatal("No route found for route added after setup")
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	startFakeBookingApp()
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{"GET", "/hotels/3", http.StatusOK, ""},
+		{"POST", "/hotels/3", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"DELETE", "/hotels", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/hotels/3/booking", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"POST", "/does/not/exist", http.StatusNotFound, ""},
+		{"OPTIONS", "/does/not/exist", http.StatusNotFound, ""},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		resp := httptest.NewRecorder()
+		handle(resp, req)
+		eq(t, tc.method+" "+tc.path+" status", resp.Code, tc.status)
+		eq(t, tc.method+" "+tc.path+" Allow header", resp.Header().Get("Allow"), tc.allow)
+	}
+}
+
+const TEST_POLICY_ROUTES = `
+GET   /hotels                  Hotels.Index
+GET   /hotels/:id/             Hotels.Show
+GET   /api/Hotels/:id.json     Api.Hotel
+POST  /hotels/:id/booking      Hotels.Book
+GET   /files/*path             Static.Serve("public")
+`
+
+func TestPathPolicies(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_POLICY_ROUTES, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+
+	type result struct {
+		method, redirect string
+	}
+	for _, tc := range []struct {
+		trailingSlash, caseInsensitive PathPolicy
+		method, path                   string
+		expected                       *result
+	}{
+		{PathStrict, PathStrict, "GET", "/hotels", &result{"Index", ""}},
+		{PathStrict, PathStrict, "GET", "/hotels/", nil},
+		{PathStrict, PathStrict, "GET", "/hotels/1", nil},
+		{PathStrict, PathStrict, "GET", "/hotels/1/", &result{"Show", ""}},
+		{PathStrict, PathStrict, "GET", "/files/css/", &result{"Serve", ""}},
+		{PathStrict, PathStrict, "GET", "/Hotels", nil},
+		{PathLenient, PathStrict, "GET", "/hotels/", &result{"Index", ""}},
+		{PathLenient, PathStrict, "GET", "/hotels/1", &result{"Show", ""}},
+		{PathLenient, PathStrict, "GET", "/Hotels/", nil},
+		{PathRedirect, PathStrict, "GET", "/hotels", &result{"Index", ""}},
+		{PathRedirect, PathStrict, "GET", "/hotels/", &result{"Index", "/hotels"}},
+		{PathRedirect, PathStrict, "HEAD", "/hotels/1", &result{"Show", "/hotels/1/"}},
+		{PathRedirect, PathStrict, "POST", "/hotels/1/booking/", &result{"Book", ""}},
+		{PathStrict, PathLenient, "GET", "/HOTELS", &result{"Index", ""}},
+		{PathStrict, PathLenient, "GET", "/HOTELS/", nil},
+		{PathStrict, PathRedirect, "GET", "/HOTELS/AbC/", &result{"Show", "/hotels/AbC/"}},
+		{PathStrict, PathRedirect, "GET", "/api/hotels/AbC.JSON", &result{"Hotel", "/api/Hotels/AbC.json"}},
+		{PathStrict, PathRedirect, "GET", "/Files/CSS/App.css", &result{"Serve", "/files/CSS/App.css"}},
+		{PathLenient, PathRedirect, "GET", "/HOTELS/", &result{"Index", "/hotels/"}},
+		{PathRedirect, PathLenient, "GET", "/HOTELS/", &result{"Index", "/hotels"}},
+		{PathLenient, PathLenient, "GET", "/HOTELS/", &result{"Index", ""}},
+	} {
+		router.TrailingSlash, router.CaseInsensitive = tc.trailingSlash, tc.caseInsensitive
+		name := fmt.Sprintf("%s %s (%d, %d)", tc.method, tc.path, tc.trailingSlash, tc.caseInsensitive)
+		route := router.Route(&http.Request{Method: tc.method, URL: &url.URL{Path: tc.path}})
+		if !eq(t, name+" found", route != nil, tc.expected != nil) || route == nil {
+			continue
+		}
+		eq(t, name+" method", route.MethodName, tc.expected.method)
+		eq(t, name+" redirect", route.Redirect, tc.expected.redirect)
+	}
+}
+
+func TestPathPolicyNoOpenRedirect(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", `
+GET /:a/:b     Hotels.Show
+GET /:a        Hotels.Index
+GET /x/*path   Static.Serve("public")
+`, false)
+	if err := router.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+	router.TrailingSlash, router.CaseInsensitive = PathRedirect, PathRedirect
+
+	for _, path := range []string{"//example.com/", "/\\example.com/", "/X//example.com/"} {
+		if route := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: path}}); route != nil {
+			t.Errorf("Expected no route for %s, got %s redirecting to %q", path, route.MethodName, route.Redirect)
+		}
+	}
+	if route := router.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/example.com/"}}); route == nil {
+		t.Error("Expected route for /example.com/")
+	} else {
+		eq(t, "redirect", route.Redirect, "/example.com")
+	}
+}
+
+func TestPathPolicyRedirect(t *testing.T) {
+	startFakeBookingApp()
+	MainRouter.TrailingSlash, MainRouter.CaseInsensitive = PathRedirect, PathRedirect
+	defer func() { MainRouter.TrailingSlash, MainRouter.CaseInsensitive = PathLenient, PathStrict }()
+
+	for _, tc := range []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/hotels/3/booking", http.StatusOK, ""},
+		{"/hotels/3/booking/", http.StatusMovedPermanently, "/hotels/3/booking"},
+		{"/Hotels/3/Booking?page=2", http.StatusMovedPermanently, "/hotels/3/booking?page=2"},
+		{"/HOTELS/3/BOOKING/", http.StatusMovedPermanently, "/hotels/3/booking"},
+		{"/hotels/3/booking/x", http.StatusNotFound, ""},
+		{"//example.com/", http.StatusNotFound, ""},
+	} {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		resp := httptest.NewRecorder()
+		handle(resp, req)
+		eq(t, tc.path+" status", resp.Code, tc.status)
+		eq(t, tc.path+" Location", resp.Header().Get("Location"), tc.location)
+	}
+}
+
+func TestPathPolicyAllowedMethods(t *testing.T) {
+	startFakeBookingApp()
+	defer func() { MainRouter.TrailingSlash, MainRouter.CaseInsensitive = PathLenient, PathStrict }()
+
+	for _, tc := range []struct {
+		trailingSlash, caseInsensitive PathPolicy
+		method, path                   string
+		status                         int
+		allow                          string
+	}{
+		{PathStrict, PathStrict, "GET", "/hotels/3/booking", http.StatusOK, ""},
+		{PathStrict, PathStrict, "GET", "/hotels/3/booking/", http.StatusNotFound, ""},
+		{PathStrict, PathStrict, "OPTIONS", "/hotels/3/booking/", http.StatusNotFound, ""},
+		{PathStrict, PathStrict, "POST", "/Hotels/3/booking", http.StatusNotFound, ""},
+		{PathLenient, PathLenient, "POST", "/Hotels/3/booking/", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{PathLenient, PathLenient, "OPTIONS", "/Hotels/3/booking", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+	} {
+		MainRouter.TrailingSlash, MainRouter.CaseInsensitive = tc.trailingSlash, tc.caseInsensitive
+		name := fmt.Sprintf("%s %s (%d, %d)", tc.method, tc.path, tc.trailingSlash, tc.caseInsensitive)
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		resp := httptest.NewRecorder()
+		handle(resp, req)
+		eq(t, name+" status", resp.Code, tc.status)
+		eq(t, name+" Allow", resp.Header().Get("Allow"), tc.allow)
+	}
+}
+
+func TestRouteAttributes(t *testing.T) {
+	startFakeBookingApp()
+	defer MainRouter.Refresh()
+
+	MainRouter.Routes, _ = parseRoutes("", "", `
+GET /hotels/:id  Hotels.Show  {auth=admin cache=60s}
+GET /hotels      Hotels.Index
+`, false)
+	if err := MainRouter.updateTree(); err != nil {
+		t.Fatalf("Error creating router: %s", err)
+	}
+
+	route := MainRouter.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/hotels/3"}})
+	eq(t, "Attribute", route.Attributes["auth"], "admin")
+
+	var attributes map[string]string
+	filters := []Filter{RouterFilter, func(c *Controller, _ []Filter) {
+		attributes = c.RouteAttributes
+	}}
+	for path, expected := range map[string]map[string]string{
+		"/hotels/3": {"auth": "admin", "cache": "60s"},
+		"/hotels":   nil,
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		c := NewController(NewRequest(req), NewResponse(httptest.NewRecorder()))
+		c.Params = &Params{}
+		attributes = nil
+		filters[0](c, filters[1:])
+		eq(t, path, fmt.Sprint(attributes), fmt.Sprint(expected))
+	}
+}
+
 func TestOverrideMethodFilter(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/hotels/3", strings.NewReader("_method=put"))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; param=value")
@@ -0,0 +1,151 @@
+package mars
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecureHeaders configures the security related headers added to responses
+// by SecureHeadersFilter. Empty values result in the header not being sent.
+type SecureHeaders struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header. It
+	// is only sent if HTTPS is enabled. In dual-stack mode, it is only sent
+	// for requests using HTTPS. The value 0 disables the header, which is the
+	// default, as browsers keep enforcing HTTPS for the whole max-age.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	ContentTypeOptions string // X-Content-Type-Options, e.g. "nosniff"
+	ReferrerPolicy     string // Referrer-Policy, e.g. "strict-origin-when-cross-origin"
+	FrameOptions       string // X-Frame-Options, e.g. "SAMEORIGIN" or "DENY"
+	PermissionsPolicy  string // Permissions-Policy, e.g. "geolocation=(), camera=()"
+
+	// ContentSecurityPolicy is the Content-Security-Policy header. Each
+	// occurrence of "{nonce}" is replaced by a source expression containing a
+	// random nonce generated for every request, like "'nonce-Tm9uY2U='". The
+	// nonce is available to templates as {{.cspNonce}}, e.g.
+	// <script nonce="{{.cspNonce}}">.
+	ContentSecurityPolicy string
+}
+
+// DefaultSecureHeaders are the headers added by SecureHeadersFilter. They are
+// configured using these settings in app.conf:
+//
+//	headers.hsts.maxage = 0
+//	headers.hsts.includesubdomains = false
+//	headers.hsts.preload = false
+//	headers.contenttypeoptions = nosniff
+//	headers.referrerpolicy = strict-origin-when-cross-origin
+//	headers.frameoptions = SAMEORIGIN
+//	headers.permissionspolicy =
+//	headers.csp =
+var DefaultSecureHeaders = SecureHeaders{
+	ContentTypeOptions: "nosniff",
+	ReferrerPolicy:     "strict-origin-when-cross-origin",
+	FrameOptions:       "SAMEORIGIN",
+}
+
+const cspNoncePlaceholder = "{nonce}"
+
+func init() {
+	OnAppStart(func() {
+		h := &DefaultSecureHeaders
+		maxAge := Config.StringDefault("headers.hsts.maxage", "")
+		if maxAge != "" {
+			var err error
+			if h.HSTSMaxAge, err = time.ParseDuration(maxAge); err != nil {
+				panic(fmt.Errorf("headers.hsts.maxage invalid: %s", err))
+			}
+		}
+		h.HSTSIncludeSubdomains = Config.BoolDefault("headers.hsts.includesubdomains", h.HSTSIncludeSubdomains)
+		h.HSTSPreload = Config.BoolDefault("headers.hsts.preload", h.HSTSPreload)
+		h.ContentTypeOptions = Config.StringDefault("headers.contenttypeoptions", h.ContentTypeOptions)
+		h.ReferrerPolicy = Config.StringDefault("headers.referrerpolicy", h.ReferrerPolicy)
+		h.FrameOptions = Config.StringDefault("headers.frameoptions", h.FrameOptions)
+		h.PermissionsPolicy = Config.StringDefault("headers.permissionspolicy", h.PermissionsPolicy)
+		h.ContentSecurityPolicy = Config.StringDefault("headers.csp", h.ContentSecurityPolicy)
+	})
+}
+
+// SecureHeadersFilter adds the security related headers configured using
+// DefaultSecureHeaders to the response.
+//
+// It is not part of the default Filters. To enable it, add it after
+// PanicFilter, so that the headers are added to all responses, including
+// errors and responses for unknown paths:
+//
+//	mars.Filters = append([]mars.Filter{mars.PanicFilter, mars.SecureHeadersFilter}, mars.Filters[1:]...)
+//
+// Thus, it is a global filter, which cannot be removed for individual
+// controllers or actions. Instead, the headers can be changed for these using
+// CustomSecureHeadersFilter, e.g. to allow framing a single page:
+//
+//	mars.FilterAction(App.Widget).
+//	  Add(mars.CustomSecureHeadersFilter(func(h *mars.SecureHeaders) {
+//	    h.FrameOptions = ""
+//	  }))
+func SecureHeadersFilter(c *Controller, fc []Filter) {
+	DefaultSecureHeaders.apply(c)
+	fc[0](c, fc[1:])
+}
+
+// CustomSecureHeadersFilter returns a filter replacing the headers added by
+// SecureHeadersFilter with the ones resulting from calling modify on a copy of
+// DefaultSecureHeaders.
+func CustomSecureHeadersFilter(modify func(h *SecureHeaders)) Filter {
+	return func(c *Controller, fc []Filter) {
+		h := DefaultSecureHeaders
+		modify(&h)
+		h.apply(c)
+		fc[0](c, fc[1:])
+	}
+}
+
+// apply sets the headers on the response, removing the ones not configured.
+func (h SecureHeaders) apply(c *Controller) {
+	header := c.Response.Out.Header()
+	set := func(name, value string) {
+		if value == "" {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+	}
+
+	hsts := ""
+	if h.HSTSMaxAge > 0 && HttpSsl && (!DualStackHTTP || c.Request.TLS != nil) {
+		hsts = "max-age=" + strconv.FormatInt(int64(h.HSTSMaxAge/time.Second), 10)
+		if h.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if h.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	set("Strict-Transport-Security", hsts)
+	set("X-Content-Type-Options", h.ContentTypeOptions)
+	set("Referrer-Policy", h.ReferrerPolicy)
+	set("X-Frame-Options", h.FrameOptions)
+	set("Permissions-Policy", h.PermissionsPolicy)
+
+	csp := h.ContentSecurityPolicy
+	if strings.Contains(csp, cspNoncePlaceholder) {
+		nonce := generateCSPNonce()
+		c.RenderArgs["cspNonce"] = nonce
+		csp = strings.ReplaceAll(csp, cspNoncePlaceholder, "'nonce-"+nonce+"'")
+	}
+	set("Content-Security-Policy", csp)
+}
+
+func generateCSPNonce() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("Unable to generate CSP nonce")
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,119 @@
+package mars
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runSecureHeadersFilter(filters []Filter, https bool) (*Controller, http.Header) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if https {
+		req.TLS = &tls.ConnectionState{}
+	}
+	c, recorder := newTestController(req)
+	filters[0](c, append(filters[1:], NilFilter))
+	return c, recorder.Header()
+}
+
+func TestSecureHeaders(t *testing.T) {
+	defer func(h SecureHeaders, ssl, dual bool) {
+		DefaultSecureHeaders, HttpSsl, DualStackHTTP = h, ssl, dual
+	}(DefaultSecureHeaders, HttpSsl, DualStackHTTP)
+
+	HttpSsl, DualStackHTTP = false, false
+	_, header := runSecureHeadersFilter([]Filter{SecureHeadersFilter}, false)
+	for name, value := range map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "SAMEORIGIN",
+		"Strict-Transport-Security": "",
+		"Permissions-Policy":        "",
+		"Content-Security-Policy":   "",
+	} {
+		if header.Get(name) != value {
+			t.Errorf("expected %s: %q, got %q", name, value, header.Get(name))
+		}
+	}
+
+	// HSTS is only sent, if configured and HTTPS is used.
+	HttpSsl = true
+	if _, header = runSecureHeadersFilter([]Filter{SecureHeadersFilter}, true); header.Get("Strict-Transport-Security") != "" {
+		t.Errorf("expected no HSTS header by default, got %q", header.Get("Strict-Transport-Security"))
+	}
+	DefaultSecureHeaders.HSTSMaxAge = 365 * 24 * time.Hour
+	DefaultSecureHeaders.HSTSIncludeSubdomains = true
+	if _, header = runSecureHeadersFilter([]Filter{SecureHeadersFilter}, false); header.Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Errorf("unexpected HSTS header %q", header.Get("Strict-Transport-Security"))
+	}
+	DualStackHTTP = true
+	if _, header = runSecureHeadersFilter([]Filter{SecureHeadersFilter}, false); header.Get("Strict-Transport-Security") != "" {
+		t.Errorf("expected no HSTS header for HTTP request in dual-stack mode, got %q", header.Get("Strict-Transport-Security"))
+	}
+	if _, header = runSecureHeadersFilter([]Filter{SecureHeadersFilter}, true); header.Get("Strict-Transport-Security") == "" {
+		t.Error("expected HSTS header for HTTPS request in dual-stack mode")
+	}
+}
+
+func TestSecureHeadersCSPNonce(t *testing.T) {
+	defer func(h SecureHeaders) { DefaultSecureHeaders = h }(DefaultSecureHeaders)
+	DefaultSecureHeaders.ContentSecurityPolicy = "default-src 'self'; script-src 'self' {nonce}; style-src {nonce}"
+
+	c, header := runSecureHeadersFilter([]Filter{SecureHeadersFilter}, false)
+	nonce, _ := c.RenderArgs["cspNonce"].(string)
+	if len(nonce) < 16 {
+		t.Fatalf("expected nonce render arg, got %q", nonce)
+	}
+	expected := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'; style-src 'nonce-" + nonce + "'"
+	if csp := header.Get("Content-Security-Policy"); csp != expected {
+		t.Errorf("expected CSP %q, got %q", expected, csp)
+	}
+
+	if other, _ := runSecureHeadersFilter([]Filter{SecureHeadersFilter}, false); other.RenderArgs["cspNonce"] == nonce {
+		t.Error("expected a new nonce for every request")
+	}
+}
+
+func TestCustomSecureHeaders(t *testing.T) {
+	custom := CustomSecureHeadersFilter(func(h *SecureHeaders) {
+		h.FrameOptions = ""
+		h.ContentSecurityPolicy = "frame-ancestors https://example.com"
+	})
+	_, header := runSecureHeadersFilter([]Filter{SecureHeadersFilter, custom}, false)
+	if header.Get("X-Frame-Options") != "" || header.Get("Content-Security-Policy") != "frame-ancestors https://example.com" {
+		t.Errorf("expected custom headers, got %v", header)
+	}
+	if header.Get("X-Content-Type-Options") != "nosniff" || !strings.HasPrefix(header.Get("Referrer-Policy"), "strict") {
+		t.Errorf("expected default headers to be kept, got %v", header)
+	}
+	if DefaultSecureHeaders.FrameOptions != "SAMEORIGIN" {
+		t.Error("expected defaults to be unchanged")
+	}
+}
+
+func TestCustomSecureHeadersForController(t *testing.T) {
+	startFakeBookingApp()
+	defer func(filters []Filter) {
+		Filters = filters
+		delete(filterOverrides, "Hotels")
+	}(Filters)
+	Filters = append([]Filter{PanicFilter, SecureHeadersFilter}, Filters[1:]...)
+
+	FilterController(Hotels{}).Add(CustomSecureHeadersFilter(func(h *SecureHeaders) {
+		h.FrameOptions = ""
+	}))
+	for path, frameOptions := range map[string]string{
+		"/hotels/3/booking": "",
+		"/static/img/x.png": "SAMEORIGIN",
+		"/unknown/path/x":   "SAMEORIGIN",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		handle(resp, req)
+		eq(t, path+" X-Frame-Options", resp.Header().Get("X-Frame-Options"), frameOptions)
+		eq(t, path+" X-Content-Type-Options", resp.Header().Get("X-Content-Type-Options"), "nosniff")
+	}
+}
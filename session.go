@@ -5,19 +5,21 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// A signed cookie (and thus limited to 4kb in size).
-// Restriction: Keys may not have a colon in them.
+// A signed cookie (and thus limited to 4kb in size), unless a SessionStore is
+// used to keep the session on the server side. Values other than strings can
+// be stored using the typed helpers, like SetInt() or SetJSON().
 type Session map[string]string
 
 const (
-	SESSION_ID_KEY = "_ID"
-	TIMESTAMP_KEY  = "_TS"
+	SESSION_ID_KEY  = "_ID"
+	TIMESTAMP_KEY   = "_TS"
+	CREATED_KEY     = "_CT" // when the session was created, in seconds since the epoch
+	LAST_ACCESS_KEY = "_LA" // when the session was last written, in seconds since the epoch
 )
 
 // expireAfterDuration is the time to live, in seconds, of a session cookie.
@@ -26,9 +28,32 @@ const (
 // sets a session cookie.
 var expireAfterDuration time.Duration
 
+// idleTimeout is the time after which a session becomes invalid, if there
+// have been no requests using it. It may be specified in config as
+// "session.idletimeout". The value 0 disables the idle timeout.
+var idleTimeout time.Duration
+
+// absoluteTimeout is the time after which a session becomes invalid after
+// having been created or regenerated, regardless of its use. It may be
+// specified in config as "session.absolutetimeout". The value 0 disables the
+// absolute timeout.
+var absoluteTimeout time.Duration
+
+// EncryptCookies controls whether the session and flash cookies are encrypted,
+// so their contents cannot be read by the client. It may be specified in config
+// as "session.encrypt". Cookies which are only signed are still accepted, so
+// the setting can be enabled without invalidating existing sessions.
+var EncryptCookies = false
+
+// encryptedCookiePrefix marks encrypted cookie values. It cannot be the first
+// character of signed or plain cookie values.
+const encryptedCookiePrefix = "~"
+
 func init() {
 	// Set expireAfterDuration, default to 24 hours if no value in config
 	OnAppStart(func() {
+		EncryptCookies = Config.BoolDefault("session.encrypt", EncryptCookies)
+
 		var err error
 		if expiresString, ok := Config.String("session.expires"); !ok {
 			expireAfterDuration = 24 * time.Hour
@@ -37,6 +62,13 @@ func init() {
 		} else if expireAfterDuration, err = time.ParseDuration(expiresString); err != nil {
 			panic(fmt.Errorf("session.expires invalid: %s", err))
 		}
+
+		if idleTimeout, err = time.ParseDuration(Config.StringDefault("session.idletimeout", "0")); err != nil {
+			panic(fmt.Errorf("session.idletimeout invalid: %s", err))
+		}
+		if absoluteTimeout, err = time.ParseDuration(Config.StringDefault("session.absolutetimeout", "0")); err != nil {
+			panic(fmt.Errorf("session.absolutetimeout invalid: %s", err))
+		}
 	})
 }
 
@@ -56,6 +88,17 @@ func (s Session) Id() string {
 	return s[SESSION_ID_KEY]
 }
 
+// Regenerate replaces the ID of this session with a new one, which should be
+// done whenever the privileges of a user change, e.g. after logging in, to
+// prevent session fixation attacks. The session data is kept, but the time of
+// creation used for the absolute timeout is reset. If a SessionStore is used,
+// the session stored for the old ID is deleted.
+func (s Session) Regenerate() string {
+	delete(s, SESSION_ID_KEY)
+	delete(s, CREATED_KEY)
+	return s.Id()
+}
+
 // getExpiration return a time.Time with the session's expiration date.
 // If previous session has set to "session", remain it
 func (s Session) getExpiration() time.Time {
@@ -66,52 +109,112 @@ func (s Session) getExpiration() time.Time {
 	return time.Now().Add(expireAfterDuration)
 }
 
-// Cookie returns an http.Cookie containing the signed session.
+// Cookie returns an http.Cookie containing the signed or, if EncryptCookies
+// is set, encrypted session. If SessionStorage is set, the session is saved to
+// the store and the cookie only contains the signed session ID.
 func (s Session) Cookie() *http.Cookie {
-	var sessionValue string
 	ts := s.getExpiration()
 	s[TIMESTAMP_KEY] = getSessionExpirationCookie(ts)
-	for key, value := range s {
-		if strings.ContainsAny(key, ":\x00") {
-			panic("Session keys may not have colons or null bytes")
-		}
-		if strings.Contains(value, "\x00") {
-			panic("Session values may not have null bytes")
-		}
-		sessionValue += "\x00" + key + ":" + value + "\x00"
+	s.touch()
+	if SessionStorage != nil {
+		return s.storeCookie(ts)
+	}
+
+	sessionData := encodeKeyValueCookie(s)
+	value := Sign(sessionData) + "/" + sessionData
+	if EncryptCookies {
+		value = encryptedCookiePrefix + Encrypt(sessionData)
 	}
+	cookie := NewCookie("SESSION", value)
+	cookie.Expires = ts.UTC()
+	return cookie
+}
 
-	sessionData := url.QueryEscape(sessionValue)
-	return &http.Cookie{
-		Name:     CookiePrefix + "_SESSION",
-		Value:    Sign(sessionData) + "/" + sessionData,
-		Domain:   CookieDomain,
-		Path:     "/",
-		HttpOnly: CookieHttpOnly,
-		Secure:   CookieSecure,
-		Expires:  ts.UTC(),
+// touch records the time of the current access and, for new sessions, the
+// time of creation.
+func (s Session) touch() {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if _, ok := s[CREATED_KEY]; !ok {
+		s[CREATED_KEY] = now
 	}
+	s[LAST_ACCESS_KEY] = now
+}
+
+// storeCookie saves the session to SessionStorage and returns a cookie
+// carrying the signed session ID.
+func (s Session) storeCookie(ts time.Time) *http.Cookie {
+	id := s.Id()
+	expires := ts
+	if expires.IsZero() {
+		expires = time.Now().Add(sessionStoreLifetime)
+	}
+	if idleTimeout > 0 && time.Now().Add(idleTimeout).Before(expires) {
+		expires = time.Now().Add(idleTimeout)
+	}
+	if err := SessionStorage.Save(id, s, expires); err != nil {
+		ERROR.Printf("Unable to save session: %s", err)
+	}
+
+	cookie := NewCookie("SESSION", Sign(id)+"/"+id)
+	cookie.Expires = ts.UTC()
+	return cookie
 }
 
 // sessionTimeoutExpiredOrMissing returns a boolean of whether the session
-// cookie is either not present or present but beyond its time to live; i.e.,
-// whether there is not a valid session.
+// cookie is either not present or present but beyond its time to live or
+// idle or absolute timeout; i.e., whether there is not a valid session.
 func sessionTimeoutExpiredOrMissing(session Session) bool {
+	now := time.Now()
 	if exp, present := session[TIMESTAMP_KEY]; !present {
 		return true
-	} else if exp == "session" {
-		return false
-	} else if expInt, _ := strconv.Atoi(exp); int64(expInt) < time.Now().Unix() {
+	} else if exp != "session" {
+		if expInt, _ := strconv.Atoi(exp); int64(expInt) < now.Unix() {
+			return true
+		}
+	}
+	if idleTimeout > 0 && timeoutExceeded(session[LAST_ACCESS_KEY], idleTimeout, now) {
+		return true
+	}
+	if absoluteTimeout > 0 && timeoutExceeded(session[CREATED_KEY], absoluteTimeout, now) {
 		return true
 	}
 	return false
 }
 
-// GetSessionFromCookie returns a Session struct pulled from the signed
-// session cookie.
+// timeoutExceeded checks whether more than the given timeout has passed since
+// the given time in seconds since the epoch. Sessions created before the
+// timestamps have been added do not time out.
+func timeoutExceeded(since string, timeout time.Duration, now time.Time) bool {
+	if since == "" {
+		return false
+	}
+	sinceInt, _ := strconv.ParseInt(since, 10, 64)
+	return now.Sub(time.Unix(sinceInt, 0)) > timeout
+}
+
+// GetSessionFromCookie returns a Session struct pulled from the signed or
+// encrypted session cookie. If SessionStorage is set, the session is loaded
+// from the store using the ID carried by the cookie. Cookies using the legacy
+// "\x00key:value\x00" format are still accepted.
 func GetSessionFromCookie(cookie *http.Cookie) Session {
 	session := make(Session)
 
+	// Encrypted cookies are authenticated, too.
+	if strings.HasPrefix(cookie.Value, encryptedCookiePrefix) {
+		data, ok := Decrypt(cookie.Value[len(encryptedCookiePrefix):])
+		if !ok {
+			INFO.Println("Session cookie decryption failed")
+			return session
+		}
+		decodeKeyValueCookie(data, func(key, val string) {
+			session[key] = val
+		})
+		if sessionTimeoutExpiredOrMissing(session) {
+			session = make(Session)
+		}
+		return session
+	}
+
 	// Separate the data from the signature.
 	sep := strings.Index(cookie.Value, "/")
 	if sep == -1 || sep >= len(cookie.Value)-1 {
@@ -125,9 +228,21 @@ func GetSessionFromCookie(cookie *http.Cookie) Session {
 		return session
 	}
 
-	parseKeyValueCookie(data, func(key, val string) {
-		session[key] = val
-	})
+	if SessionStorage != nil {
+		stored, err := SessionStorage.Load(data)
+		if err != nil {
+			ERROR.Printf("Unable to load session: %s", err)
+		} else if stored != nil && stored[SESSION_ID_KEY] == data {
+			session = stored
+			if sessionTimeoutExpiredOrMissing(session) {
+				SessionStorage.Delete(data)
+			}
+		}
+	} else {
+		decodeKeyValueCookie(data, func(key, val string) {
+			session[key] = val
+		})
+	}
 
 	if sessionTimeoutExpiredOrMissing(session) {
 		session = make(Session)
@@ -138,16 +253,32 @@ func GetSessionFromCookie(cookie *http.Cookie) Session {
 
 // SessionFilter is a Mars Filter that retrieves and sets the session cookie.
 // Within Mars, it is available as a Session attribute on Controller instances.
-// The name of the Session cookie is set as CookiePrefix + "_SESSION".
+// The name of the Session cookie is set as CookiePrefix + "_SESSION", preceded
+// by CookieSecurePrefix if set.
+// Sessions emptied or regenerated while using a SessionStore are deleted from
+// the store. Non-empty sessions are written on every request, which moves the
+// cookie's expiration date and the idle timeout forward.
 func SessionFilter(c *Controller, fc []Filter) {
 	c.Session = restoreSession(c.Request.Request)
 	sessionWasEmpty := len(c.Session) == 0
+	sessionId := c.Session[SESSION_ID_KEY]
 
 	// Make session vars available in templates as {{.session.xyz}}
 	c.RenderArgs["session"] = c.Session
 
 	fc[0](c, fc[1:])
 
+	// Remove sessions from the store that have been emptied or replaced.
+	if SessionStorage != nil && sessionId != "" && c.Session[SESSION_ID_KEY] != sessionId {
+		if err := SessionStorage.Delete(sessionId); err != nil {
+			ERROR.Printf("Unable to delete session: %s", err)
+		}
+		if len(c.Session) == 0 {
+			c.SetCookie(removalCookie("SESSION"))
+			return
+		}
+	}
+
 	// Store the signed session if it could have changed.
 	if len(c.Session) > 0 || !sessionWasEmpty {
 		c.SetCookie(c.Session.Cookie())
@@ -157,7 +288,7 @@ func SessionFilter(c *Controller, fc []Filter) {
 // restoreSession returns either the current session, retrieved from the
 // session cookie, or a new session.
 func restoreSession(req *http.Request) Session {
-	cookie, err := req.Cookie(CookiePrefix + "_SESSION")
+	cookie, err := req.Cookie(cookieName("SESSION"))
 	if err != nil {
 		return make(Session)
 	} else {
@@ -0,0 +1,265 @@
+package mars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A SessionStore keeps session data on the server side. If one is set as
+// SessionStorage, the session cookie only carries the signed session ID and
+// sessions can be revoked by deleting them from the store.
+//
+// Implementations need to be safe for concurrent use.
+type SessionStore interface {
+	// Load returns the session stored for the given ID, or nil if there is no
+	// such session or it has expired.
+	Load(id string) (Session, error)
+	// Save stores the session for the given ID until it expires.
+	Save(id string, session Session, expires time.Time) error
+	// Delete removes the session with the given ID. Deleting an unknown
+	// session is not an error.
+	Delete(id string) error
+}
+
+// SessionStorage is the store used by SessionFilter. If nil, the whole session
+// is kept in the session cookie. It may be specified in config as
+// "session.store", using either "cookie", "memory" or "file". The directory
+// used by the file store must be configured using "session.store.path".
+var SessionStorage SessionStore
+
+// sessionStoreLifetime is how long sessions lasting until the browser is
+// closed are kept by a store since the last request.
+const sessionStoreLifetime = 24 * time.Hour
+
+// sessionSweepInterval is how often stores remove expired sessions.
+const sessionSweepInterval = 10 * time.Minute
+
+var validSessionId = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func init() {
+	OnAppStart(func() {
+		store, err := newSessionStore(Config.StringDefault("session.store", ""),
+			Config.StringDefault("session.store.path", ""))
+		if err != nil {
+			panic(fmt.Errorf("session.store invalid: %s", err))
+		}
+		if store != nil {
+			SessionStorage = store
+		}
+	})
+}
+
+// newSessionStore returns the session store with the given name, or nil for
+// the cookie store.
+func newSessionStore(name, path string) (SessionStore, error) {
+	switch name {
+	case "", "cookie":
+		return nil, nil
+	case "memory":
+		return NewMemorySessionStore(), nil
+	case "file":
+		return NewFileSessionStore(path)
+	}
+	return nil, fmt.Errorf("unknown store %s", name)
+}
+
+func copySession(s Session) Session {
+	c := make(Session, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// MemorySessionStore keeps sessions in memory. All sessions are lost when
+// the application restarts, and they are not shared between multiple
+// instances of an application.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	data    Session
+	expires time.Time
+}
+
+// NewMemorySessionStore returns an empty in-memory session store.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession), lastSweep: time.Now()}
+}
+
+func (m *MemorySessionStore) Load(id string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(s.expires) {
+		delete(m.sessions, id)
+		return nil, nil
+	}
+	return copySession(s.data), nil
+}
+
+func (m *MemorySessionStore) Save(id string, session Session, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > sessionSweepInterval {
+		for k, s := range m.sessions {
+			if now.After(s.expires) {
+				delete(m.sessions, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	m.sessions[id] = memorySession{copySession(session), expires}
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// FileSessionStore keeps every session in a file of its own within a
+// directory, which allows sessions to survive restarts of the application.
+type FileSessionStore struct {
+	dir string
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+type fileSession struct {
+	Expires int64   `json:"expires"`
+	Data    Session `json:"data"`
+}
+
+// NewFileSessionStore returns a session store using the given directory,
+// which is created if it does not exist yet. As anyone able to write to the
+// directory can forge sessions, it must not be shared with other users.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if dir == "" {
+		return nil, errors.New("no directory given for file store")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir, lastSweep: time.Now()}, nil
+}
+
+func (f *FileSessionStore) path(id string) (string, error) {
+	if !validSessionId.MatchString(id) {
+		return "", errors.New("invalid session id")
+	}
+	return filepath.Join(f.dir, id+".session"), nil
+}
+
+func (f *FileSessionStore) Load(id string) (Session, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var s fileSession
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > s.Expires {
+		os.Remove(path)
+		return nil, nil
+	}
+	if s.Data == nil {
+		s.Data = make(Session)
+	}
+	return s.Data, nil
+}
+
+func (f *FileSessionStore) Save(id string, session Session, expires time.Time) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(fileSession{Expires: expires.Unix(), Data: session})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so concurrent requests never read a
+	// partially written session.
+	tmp, err := os.CreateTemp(f.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.lastSweep) > sessionSweepInterval {
+		f.lastSweep = time.Now()
+		go f.sweep()
+	}
+	return nil
+}
+
+func (f *FileSessionStore) Delete(id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sweep removes the files of all expired sessions.
+func (f *FileSessionStore) sweep() {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		WARN.Printf("Unable to remove expired sessions: %s", err)
+		return
+	}
+	for _, entry := range entries {
+		if id := strings.TrimSuffix(entry.Name(), ".session"); id != entry.Name() {
+			f.Load(id)
+		}
+	}
+}
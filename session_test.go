@@ -2,6 +2,10 @@ package mars
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -62,3 +66,247 @@ func TestSessionExpire(t *testing.T) {
 		t.Error("expect expires", cookie.Expires, "before", expectExpire)
 	}
 }
+
+func runSessionFilter(cookie *http.Cookie, action func(s Session)) *http.Cookie {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	c := NewController(NewRequest(req), NewResponse(resp))
+	SessionFilter(c, []Filter{func(c *Controller, _ []Filter) { action(c.Session) }})
+
+	cookies := resp.Result().Cookies()
+	if len(cookies) == 0 {
+		return nil
+	}
+	return cookies[0]
+}
+
+func TestSessionStorage(t *testing.T) {
+	expireAfterDuration = time.Hour
+	store := NewMemorySessionStore()
+	SessionStorage = store
+	defer func() { SessionStorage = nil }()
+
+	var id string
+	cookie := runSessionFilter(nil, func(s Session) {
+		s["user"] = "Tom"
+		s["data"] = strings.Repeat("x", 8192)
+		id = s.Id()
+	})
+	if cookie == nil {
+		t.Fatal("expected session cookie")
+	}
+	if cookie.Value != Sign(id)+"/"+id {
+		t.Errorf("expected cookie to only contain the signed session id, got %s", cookie.Value)
+	}
+	if session, _ := store.Load(id); session == nil || session["user"] != "Tom" {
+		t.Errorf("expected session to be stored, got %v", session)
+	}
+
+	runSessionFilter(cookie, func(s Session) {
+		if s["user"] != "Tom" || s.Id() != id {
+			t.Errorf("expected stored session to be restored, got %v", s)
+		}
+	})
+
+	// Tampered and revoked session ids result in a new session.
+	runSessionFilter(&http.Cookie{Name: cookie.Name, Value: Sign(id) + "/x" + id}, func(s Session) {
+		if len(s) != 0 {
+			t.Errorf("expected empty session for invalid signature, got %v", s)
+		}
+	})
+	store.Delete(id)
+	runSessionFilter(cookie, func(s Session) {
+		if len(s) != 0 {
+			t.Errorf("expected empty session after revocation, got %v", s)
+		}
+		s["user"] = "Tom"
+		if s.Id() == id {
+			t.Error("expected revoked session id not to be reused")
+		}
+	})
+
+	// Emptying the session deletes it from the store.
+	cookie = runSessionFilter(nil, func(s Session) {
+		s["user"] = "Tom"
+		id = s.Id()
+	})
+	cookie = runSessionFilter(cookie, func(s Session) {
+		for k := range s {
+			delete(s, k)
+		}
+	})
+	if cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("expected session cookie to be removed, got %v", cookie)
+	}
+	if session, _ := store.Load(id); session != nil {
+		t.Errorf("expected session to be deleted, got %v", session)
+	}
+}
+
+func TestEncryptedSession(t *testing.T) {
+	expireAfterDuration = time.Hour
+	session := Session{"user": "42", "beta": "true"}
+	signed := session.Cookie()
+
+	EncryptCookies = true
+	defer func() { EncryptCookies = false }()
+
+	cookie := session.Cookie()
+	if !strings.HasPrefix(cookie.Value, encryptedCookiePrefix) || strings.Contains(cookie.Value, "beta") {
+		t.Errorf("expected encrypted cookie, got %s", cookie.Value)
+	}
+	if restored := GetSessionFromCookie(cookie); restored["user"] != "42" || restored["beta"] != "true" {
+		t.Errorf("encrypted session restore failed: %v", restored)
+	}
+
+	// Signed cookies are still accepted.
+	if restored := GetSessionFromCookie(signed); restored["user"] != "42" {
+		t.Errorf("signed session restore failed: %v", restored)
+	}
+
+	tampered := []byte(cookie.Value)
+	tampered[len(tampered)/2] ^= 1
+	if restored := GetSessionFromCookie(&http.Cookie{Value: string(tampered)}); len(restored) != 0 {
+		t.Errorf("expected tampered session to be rejected, got %v", restored)
+	}
+}
+
+func TestSessionSecretRotation(t *testing.T) {
+	expireAfterDuration = time.Hour
+	defer SetPreviousAppSecrets()
+
+	SetAppSecret("Old secret")
+	cookie := Session{"user": "Tom"}.Cookie()
+
+	SetAppSecret("New secret")
+	SetPreviousAppSecrets("Old secret")
+
+	// The session is restored and signed using the new secret.
+	resigned := runSessionFilter(cookie, func(s Session) {
+		if s["user"] != "Tom" {
+			t.Errorf("expected session signed with previous secret to be restored, got %v", s)
+		}
+	})
+	if resigned == nil {
+		t.Fatal("expected session cookie to be re-signed")
+	}
+
+	SetPreviousAppSecrets()
+	if s := GetSessionFromCookie(cookie); len(s) != 0 {
+		t.Errorf("expected session signed with removed secret to be rejected, got %v", s)
+	}
+	if s := GetSessionFromCookie(resigned); s["user"] != "Tom" {
+		t.Errorf("expected re-signed session to be restored, got %v", s)
+	}
+}
+
+func TestSessionArbitraryKeys(t *testing.T) {
+	expireAfterDuration = time.Hour
+	session := Session{"a:b": "c:d", "nul\x00key": "nul\x00value", "amp&=": "100% +1; \"quoted\""}
+	cookie := session.Cookie()
+	if !strings.Contains(cookie.Value, "/"+keyValueCookieFormat) {
+		t.Errorf("expected cookie to use the current format, got %s", cookie.Value)
+	}
+
+	restored := GetSessionFromCookie(cookie)
+	for k, v := range session {
+		if restored[k] != v {
+			t.Errorf("session restore failed session[%q] = %q, expected %q", k, restored[k], v)
+		}
+	}
+}
+
+func TestLegacySessionCookie(t *testing.T) {
+	ts := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	data := url.QueryEscape("\x00user:Tom\x00\x00" + TIMESTAMP_KEY + ":" + ts + "\x00")
+	cookie := &http.Cookie{Name: CookiePrefix + "_SESSION", Value: Sign(data) + "/" + data}
+
+	// Legacy cookies are still accepted and migrated to the current format.
+	resp := runSessionFilter(cookie, func(s Session) {
+		if s["user"] != "Tom" {
+			t.Errorf("legacy session restore failed: %v", s)
+		}
+	})
+	if resp == nil || !strings.Contains(resp.Value, "/"+keyValueCookieFormat) {
+		t.Errorf("expected session to be migrated, got %v", resp)
+	}
+	if s := GetSessionFromCookie(resp); s["user"] != "Tom" {
+		t.Errorf("migrated session restore failed: %v", s)
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	expireAfterDuration = time.Hour
+	defer func() { idleTimeout, absoluteTimeout = 0, 0 }()
+
+	ago := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(-d).Unix(), 10)
+	}
+	session := Session{"user": "Tom"}
+	session.Cookie()
+	if session[CREATED_KEY] == "" || session[LAST_ACCESS_KEY] == "" {
+		t.Fatalf("expected session timestamps to be set, got %v", session)
+	}
+
+	idleTimeout = 10 * time.Minute
+	session[LAST_ACCESS_KEY] = ago(5 * time.Minute)
+	if sessionTimeoutExpiredOrMissing(session) {
+		t.Error("expected session within idle timeout to be valid")
+	}
+	session[LAST_ACCESS_KEY] = ago(15 * time.Minute)
+	if !sessionTimeoutExpiredOrMissing(session) {
+		t.Error("expected idle session to be expired")
+	}
+
+	// Writing the session moves the idle timeout forward, but not the absolute one.
+	absoluteTimeout = time.Hour
+	session[CREATED_KEY] = ago(30 * time.Minute)
+	restored := GetSessionFromCookie(session.Cookie())
+	if restored["user"] != "Tom" || restored[CREATED_KEY] != session[CREATED_KEY] {
+		t.Errorf("expected refreshed session, got %v", restored)
+	}
+	session[CREATED_KEY] = ago(2 * time.Hour)
+	if restored := GetSessionFromCookie(session.Cookie()); len(restored) != 0 {
+		t.Errorf("expected session beyond absolute timeout to be expired, got %v", restored)
+	}
+
+	// Sessions without timestamps do not time out.
+	delete(session, CREATED_KEY)
+	delete(session, LAST_ACCESS_KEY)
+	if sessionTimeoutExpiredOrMissing(session) {
+		t.Error("expected session without timestamps to be valid")
+	}
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	expireAfterDuration = time.Hour
+	store := NewMemorySessionStore()
+	SessionStorage = store
+	defer func() { SessionStorage = nil }()
+
+	var oldId, newId string
+	cookie := runSessionFilter(nil, func(s Session) {
+		s["user"] = "Tom"
+		oldId = s.Id()
+	})
+	cookie = runSessionFilter(cookie, func(s Session) {
+		created := s[CREATED_KEY]
+		newId = s.Regenerate()
+		if newId == oldId || s.Id() != newId || s["user"] != "Tom" {
+			t.Errorf("unexpected regenerated session %v", s)
+		}
+		if _, ok := s[CREATED_KEY]; ok || created == "" {
+			t.Errorf("expected time of creation to be reset, got %v", s)
+		}
+	})
+
+	if s, _ := store.Load(oldId); s != nil {
+		t.Errorf("expected old session to be deleted, got %v", s)
+	}
+	if s := GetSessionFromCookie(cookie); s.Id() != newId || s["user"] != "Tom" {
+		t.Errorf("expected regenerated session, got %v", s)
+	}
+}
@@ -0,0 +1,95 @@
+package mars
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrNoSessionValue is returned by the typed Session getters, if the session
+// does not contain the given key.
+var ErrNoSessionValue = errors.New("no such session value")
+
+func (s Session) get(key string) (string, error) {
+	val, ok := s[key]
+	if !ok {
+		return "", ErrNoSessionValue
+	}
+	return val, nil
+}
+
+// SetInt stores an integer in the session.
+func (s Session) SetInt(key string, value int) {
+	s[key] = strconv.Itoa(value)
+}
+
+// GetInt returns an integer stored using SetInt.
+func (s Session) GetInt(key string) (int, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(val)
+}
+
+// SetInt64 stores a 64-bit integer in the session.
+func (s Session) SetInt64(key string, value int64) {
+	s[key] = strconv.FormatInt(value, 10)
+}
+
+// GetInt64 returns a 64-bit integer stored using SetInt64 or SetInt.
+func (s Session) GetInt64(key string) (int64, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+// SetBool stores a boolean in the session.
+func (s Session) SetBool(key string, value bool) {
+	s[key] = strconv.FormatBool(value)
+}
+
+// GetBool returns a boolean stored using SetBool.
+func (s Session) GetBool(key string) (bool, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(val)
+}
+
+// SetTime stores a point in time in the session, with nanosecond precision.
+func (s Session) SetTime(key string, value time.Time) {
+	s[key] = value.Format(time.RFC3339Nano)
+}
+
+// GetTime returns a point in time stored using SetTime.
+func (s Session) GetTime(key string) (time.Time, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, val)
+}
+
+// SetJSON stores the JSON encoding of the given value in the session.
+func (s Session) SetJSON(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s[key] = string(data)
+	return nil
+}
+
+// GetJSON decodes a value stored using SetJSON into the value pointed to by v.
+func (s Session) GetJSON(key string, v interface{}) error {
+	val, err := s.get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), v)
+}
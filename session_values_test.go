@@ -0,0 +1,55 @@
+package mars
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedSessionValues(t *testing.T) {
+	expireAfterDuration = time.Hour
+	type prefs struct {
+		Lang  string
+		Flags []string
+	}
+
+	now := time.Now()
+	session := make(Session)
+	session.SetInt("user", 42)
+	session.SetInt64("big", 1<<40)
+	session.SetBool("beta", true)
+	session.SetTime("login", now)
+	if err := session.SetJSON("prefs", prefs{"de", []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values survive a round trip through the cookie.
+	session = GetSessionFromCookie(session.Cookie())
+
+	if v, err := session.GetInt("user"); err != nil || v != 42 {
+		t.Errorf("GetInt: %v %v", v, err)
+	}
+	if v, err := session.GetInt64("big"); err != nil || v != 1<<40 {
+		t.Errorf("GetInt64: %v %v", v, err)
+	}
+	if v, err := session.GetBool("beta"); err != nil || !v {
+		t.Errorf("GetBool: %v %v", v, err)
+	}
+	if v, err := session.GetTime("login"); err != nil || !v.Equal(now) {
+		t.Errorf("GetTime: %v %v", v, err)
+	}
+	var p prefs
+	if err := session.GetJSON("prefs", &p); err != nil || !reflect.DeepEqual(p, prefs{"de", []string{"a", "b"}}) {
+		t.Errorf("GetJSON: %v %v", p, err)
+	}
+
+	if _, err := session.GetInt("missing"); err != ErrNoSessionValue {
+		t.Errorf("expected ErrNoSessionValue, got %v", err)
+	}
+	if err := session.GetJSON("missing", &p); err != ErrNoSessionValue {
+		t.Errorf("expected ErrNoSessionValue, got %v", err)
+	}
+	if _, err := session.GetInt("prefs"); err == nil {
+		t.Error("expected error for non-integer value")
+	}
+}
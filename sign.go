@@ -1,6 +1,8 @@
 package mars
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -13,13 +15,31 @@ var HashBlockSize = sha256.BlockSize
 
 var (
 	// Private
-	secretKey []byte // Key used to sign cookies.
+	secretKey          []byte   // Key used to sign cookies.
+	previousSecretKeys [][]byte // Keys used before rotating the secret, only used for verifying.
 )
 
 func SetAppSecret(secret string) {
 	secretKey = []byte(secret)
 }
 
+// SetPreviousAppSecrets sets the secrets that have been used before the
+// current one. Signatures and encrypted cookies created using these are still
+// accepted, which allows rotating the secret without logging out all users.
+// Cookies verified using a previous secret are signed using the current one
+// when being sent to the client again.
+func SetPreviousAppSecrets(secrets ...string) {
+	previousSecretKeys = nil
+	for _, secret := range secrets {
+		previousSecretKeys = append(previousSecretKeys, []byte(secret))
+	}
+}
+
+// secretKeys returns the current secret key followed by the previous ones.
+func secretKeys() [][]byte {
+	return append([][]byte{secretKey}, previousSecretKeys...)
+}
+
 func generateRandomSecretKey() []byte {
 	buf := make([]byte, HashBlockSize)
 	if _, err := rand.Read(buf); err != nil {
@@ -40,13 +60,70 @@ func Sign(message string) string {
 }
 
 // Verify returns true if the given signature is correct for the given message.
-// e.g. it matches what we generate with Sign()
+// e.g. it matches what we generate with Sign() using the current or one of the
+// previous secret keys.
 func Verify(message, sig string) bool {
-	// return hmac.Equal([]byte(sig), []byte(Sign(message)))
-	mac := hmac.New(HashAlgorithm, secretKey)
-	io.WriteString(mac, message)
-	hash := mac.Sum(nil)
-
 	received, _ := base64.RawURLEncoding.DecodeString(sig)
-	return hmac.Equal(received, hash)
+	for _, key := range secretKeys() {
+		mac := hmac.New(HashAlgorithm, key)
+		io.WriteString(mac, message)
+		if hmac.Equal(received, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptionKey derives the key used for encrypting cookies from the secret
+// key, so the same key is never used for signing and encrypting.
+func encryptionKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, "mars cookie encryption")
+	return mac.Sum(nil)
+}
+
+func newAEAD(secret []byte) cipher.AEAD {
+	block, err := aes.NewCipher(encryptionKey(secret))
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// Encrypt encrypts and authenticates a given string using AES-GCM with a key
+// derived from the configured or random secret key.
+// Returns a random nonce followed by the ciphertext in unpadded, URL-safe
+// base64 encoding (A-Z, 0-9, a-z, _ and -).
+func Encrypt(message string) string {
+	aead := newAEAD(secretKey)
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(message)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic("Unable to generate nonce")
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(message), nil))
+}
+
+// Decrypt returns the message encrypted using Encrypt() with the current or
+// one of the previous secret keys and true, or false if the ciphertext cannot
+// be decrypted or has been tampered with.
+func Decrypt(ciphertext string) (string, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", false
+	}
+	for _, key := range secretKeys() {
+		aead := newAEAD(key)
+		if len(data) < aead.NonceSize() {
+			return "", false
+		}
+		message, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+		if err == nil {
+			return string(message), true
+		}
+	}
+	return "", false
 }
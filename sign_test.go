@@ -2,6 +2,7 @@ package mars
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/roblillack/mars/internal/pathtree"
@@ -81,3 +82,62 @@ func TestSignatureWithRandomSecret(t *testing.T) {
 		}
 	}
 }
+
+func TestEncryption(t *testing.T) {
+	SetAppSecret("Eriks eifrige Eichhörnchen erschreckten Emmas eitle Enten.")
+
+	msg := "user:42 beta:true"
+	ciphertext := Encrypt(msg)
+	if ciphertext == msg || strings.ContainsAny(ciphertext, "/+=") {
+		t.Fatalf("unexpected ciphertext '%s'!", ciphertext)
+	}
+	if other := Encrypt(msg); other == ciphertext {
+		t.Fatalf("encrypting twice resulted in the same ciphertext '%s'!", ciphertext)
+	}
+	if r, ok := Decrypt(ciphertext); !ok || r != msg {
+		t.Fatalf("wrong message '%s' decrypted from '%s'!", r, ciphertext)
+	}
+
+	// Tampered or truncated ciphertexts must be rejected.
+	tampered := []byte(ciphertext)
+	tampered[len(tampered)/2] ^= 1
+	for _, c := range []string{string(tampered), ciphertext[:10], "", "!"} {
+		if r, ok := Decrypt(c); ok {
+			t.Fatalf("decrypted '%s' from invalid ciphertext '%s'!", r, c)
+		}
+	}
+
+	SetAppSecret("Another secret")
+	if r, ok := Decrypt(ciphertext); ok {
+		t.Fatalf("decrypted '%s' using the wrong secret!", r)
+	}
+}
+
+func TestSecretRotation(t *testing.T) {
+	defer SetPreviousAppSecrets()
+
+	SetAppSecret("Old secret")
+	sig := Sign("Untouchable")
+	ciphertext := Encrypt("Untouchable")
+
+	SetAppSecret("New secret")
+	if Verify("Untouchable", sig) {
+		t.Fatal("signature of previous secret verified without configuring it")
+	}
+
+	SetPreviousAppSecrets("Older secret", "Old secret")
+	if !Verify("Untouchable", sig) {
+		t.Fatal("signature of previous secret cannot be verified")
+	}
+	if r, ok := Decrypt(ciphertext); !ok || r != "Untouchable" {
+		t.Fatalf("cannot decrypt using previous secret, got '%s'", r)
+	}
+	if Sign("Untouchable") == sig {
+		t.Fatal("expected signing to use the current secret")
+	}
+
+	SetPreviousAppSecrets("Older secret")
+	if Verify("Untouchable", sig) {
+		t.Fatal("signature of removed secret verified")
+	}
+}
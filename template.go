@@ -512,6 +512,8 @@ var _ Template = goTemplateWrapper{}
 
 // Return a url capable of invoking a given controller method:
 // "Application.ShowApp 123" => "/app/123"
+// Named routes can be used instead of the controller method:
+// "hotel 123" => "/hotels/123"
 func ReverseUrl(args ...interface{}) (template.URL, error) {
 	if len(args) == 0 {
 		return "", fmt.Errorf("no arguments provided to reverse route")
@@ -521,29 +523,61 @@ func ReverseUrl(args ...interface{}) (template.URL, error) {
 	if action == "Root" {
 		return template.URL(AppRoot), nil
 	}
-	actionSplit := strings.Split(action, ".")
-	if len(actionSplit) != 2 {
-		return "", fmt.Errorf("reversing '%s', expected 'Controller.Action'", action)
+
+	actionDef, err := reverseAction(action, args[1:])
+	if err != nil {
+		return "", err
 	}
 
-	// Look up the types.
-	var c Controller
-	if err := c.SetAction(actionSplit[0], actionSplit[1]); err != nil {
-		return "", fmt.Errorf("reversing %s: %s", action, err)
+	return template.URL(actionDef.Url), nil
+}
+
+// reverseAction looks up the route for the given action or route name, using
+// the arguments of the action's method to build the URL.
+func reverseAction(action string, args []interface{}) (*ActionDefinition, error) {
+	methodAction := action
+	if !strings.Contains(action, ".") {
+		route := MainRouter.namedRoute(action)
+		if route == nil {
+			return nil, fmt.Errorf("reversing '%s', no route with this name", action)
+		}
+		methodAction = route.Action
 	}
 
-	if len(c.MethodType.Args) < len(args)-1 {
-		return "", fmt.Errorf("reversing %s: route defines %d args, but received %d",
-			action, len(c.MethodType.Args), len(args)-1)
+	actionSplit := strings.Split(methodAction, ".")
+	if len(actionSplit) != 2 {
+		return nil, fmt.Errorf("reversing '%s', expected 'Controller.Action'", methodAction)
 	}
 
-	// Unbind the arguments.
 	argsByName := make(map[string]string)
-	for i, argValue := range args[1:] {
-		Unbind(argsByName, c.MethodType.Args[i].Name, argValue)
+	if strings.HasPrefix(actionSplit[0], ":") || strings.HasPrefix(actionSplit[1], ":") {
+		// Named routes with a variable action can only be reversed without args.
+		if len(args) > 0 {
+			return nil, fmt.Errorf("reversing %s: cannot pass arguments to route with action %s", action, methodAction)
+		}
+	} else {
+		// Look up the types.
+		var c Controller
+		if err := c.SetAction(actionSplit[0], actionSplit[1]); err != nil {
+			return nil, fmt.Errorf("reversing %s: %s", action, err)
+		}
+
+		if len(c.MethodType.Args) < len(args) {
+			return nil, fmt.Errorf("reversing %s: route defines %d args, but received %d",
+				action, len(c.MethodType.Args), len(args))
+		}
+
+		// Unbind the arguments.
+		for i, argValue := range args {
+			Unbind(argsByName, c.MethodType.Args[i].Name, argValue)
+		}
 	}
 
-	return template.URL(MainRouter.Reverse(args[0].(string), argsByName).Url), nil
+	actionDef := MainRouter.Reverse(action, argsByName)
+	if actionDef == nil {
+		return nil, fmt.Errorf("reversing %s: no route found", action)
+	}
+	return actionDef, nil
 }
 
 func Slug(text string) string {
@@ -2,6 +2,8 @@ greeting=Hallo
 greeting.name=Rob
 greeting.suffix=, welkom bij Mars!
 
+mars.bind.int=Moet een geheel getal zijn
+
 [NL]
 greeting=Goeiedag
 
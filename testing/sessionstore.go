@@ -0,0 +1,168 @@
+package testing
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roblillack/mars"
+)
+
+// SessionStoreContract runs the tests every mars.SessionStore implementation
+// has to pass. newStore is called for each test and needs to return an empty
+// store. Use it to test custom stores, e.g. one backed by Redis or SQL:
+//
+//	func TestRedisStore(t *testing.T) {
+//		testing.SessionStoreContract(t, func(t *testing.T) mars.SessionStore {
+//			return NewRedisStore(...)
+//		})
+//	}
+func SessionStoreContract(t *testing.T, newStore func(t *testing.T) mars.SessionStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store mars.SessionStore)
+	}{
+		{"LoadUnknown", testLoadUnknownSession},
+		{"SaveAndLoad", testSaveAndLoadSession},
+		{"Overwrite", testOverwriteSession},
+		{"Delete", testDeleteSession},
+		{"Expire", testExpireSession},
+		{"Isolation", testSessionIsolation},
+		{"Concurrency", testConcurrentSessions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func sampleSession(id string) mars.Session {
+	return mars.Session{mars.SESSION_ID_KEY: id, "user": "roblillack", "lang": "de"}
+}
+
+func mustLoad(t *testing.T, store mars.SessionStore, id string) mars.Session {
+	t.Helper()
+	session, err := store.Load(id)
+	if err != nil {
+		t.Fatalf("Load(%s) failed: %s", id, err)
+	}
+	return session
+}
+
+func mustSave(t *testing.T, store mars.SessionStore, id string, session mars.Session, expires time.Time) {
+	t.Helper()
+	if err := store.Save(id, session, expires); err != nil {
+		t.Fatalf("Save(%s) failed: %s", id, err)
+	}
+}
+
+func testLoadUnknownSession(t *testing.T, store mars.SessionStore) {
+	if session := mustLoad(t, store, "unknown"); session != nil {
+		t.Errorf("Expected no session, got %v", session)
+	}
+}
+
+func testSaveAndLoadSession(t *testing.T, store mars.SessionStore) {
+	session := sampleSession("abc")
+	mustSave(t, store, "abc", session, time.Now().Add(time.Hour))
+
+	loaded := mustLoad(t, store, "abc")
+	if !reflect.DeepEqual(loaded, session) {
+		t.Errorf("Expected %v, got %v", session, loaded)
+	}
+
+	// Changing the saved or loaded session must not affect the store.
+	session["user"] = "changed"
+	loaded["lang"] = "changed"
+	if loaded := mustLoad(t, store, "abc"); !reflect.DeepEqual(loaded, sampleSession("abc")) {
+		t.Errorf("Expected stored session to be unchanged, got %v", loaded)
+	}
+}
+
+func testOverwriteSession(t *testing.T, store mars.SessionStore) {
+	mustSave(t, store, "abc", sampleSession("abc"), time.Now().Add(time.Hour))
+	session := mars.Session{mars.SESSION_ID_KEY: "abc", "user": "other"}
+	mustSave(t, store, "abc", session, time.Now().Add(time.Hour))
+
+	if loaded := mustLoad(t, store, "abc"); !reflect.DeepEqual(loaded, session) {
+		t.Errorf("Expected %v, got %v", session, loaded)
+	}
+}
+
+func testDeleteSession(t *testing.T, store mars.SessionStore) {
+	mustSave(t, store, "abc", sampleSession("abc"), time.Now().Add(time.Hour))
+	if err := store.Delete("abc"); err != nil {
+		t.Fatalf("Delete failed: %s", err)
+	}
+	if session := mustLoad(t, store, "abc"); session != nil {
+		t.Errorf("Expected deleted session to be gone, got %v", session)
+	}
+	if err := store.Delete("abc"); err != nil {
+		t.Errorf("Deleting unknown session failed: %s", err)
+	}
+}
+
+func testExpireSession(t *testing.T, store mars.SessionStore) {
+	mustSave(t, store, "expired", sampleSession("expired"), time.Now().Add(-time.Second))
+	if session := mustLoad(t, store, "expired"); session != nil {
+		t.Errorf("Expected expired session to be gone, got %v", session)
+	}
+
+	mustSave(t, store, "abc", sampleSession("abc"), time.Now().Add(time.Hour))
+	mustSave(t, store, "abc", sampleSession("abc"), time.Now().Add(-time.Second))
+	if session := mustLoad(t, store, "abc"); session != nil {
+		t.Errorf("Expected session saved with past expiration to be gone, got %v", session)
+	}
+}
+
+func testSessionIsolation(t *testing.T, store mars.SessionStore) {
+	mustSave(t, store, "a", sampleSession("a"), time.Now().Add(time.Hour))
+	mustSave(t, store, "b", sampleSession("b"), time.Now().Add(time.Hour))
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %s", err)
+	}
+
+	if session := mustLoad(t, store, "a"); session != nil {
+		t.Errorf("Expected deleted session to be gone, got %v", session)
+	}
+	if loaded := mustLoad(t, store, "b"); !reflect.DeepEqual(loaded, sampleSession("b")) {
+		t.Errorf("Expected %v, got %v", sampleSession("b"), loaded)
+	}
+}
+
+func testConcurrentSessions(t *testing.T, store mars.SessionStore) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("session%d", i%5)
+			session := sampleSession(id)
+			session["n"] = fmt.Sprint(i)
+			if err := store.Save(id, session, time.Now().Add(time.Hour)); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := store.Load(id); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent access failed: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("session%d", i)
+		if session := mustLoad(t, store, id); session == nil || session[mars.SESSION_ID_KEY] != id {
+			t.Errorf("Expected session %s, got %v", id, session)
+		}
+	}
+}
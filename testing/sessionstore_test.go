@@ -0,0 +1,29 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/roblillack/mars"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	SessionStoreContract(t, func(t *testing.T) mars.SessionStore {
+		return mars.NewMemorySessionStore()
+	})
+}
+
+func TestFileSessionStore(t *testing.T) {
+	SessionStoreContract(t, func(t *testing.T) mars.SessionStore {
+		store, err := mars.NewFileSessionStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestFileSessionStoreNeedsDirectory(t *testing.T) {
+	if _, err := mars.NewFileSessionStore(""); err == nil {
+		t.Error("Expected error for missing directory")
+	}
+}
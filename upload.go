@@ -0,0 +1,182 @@
+package mars
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// An Upload is a file uploaded using a multipart form. Unlike binding uploads
+// to *os.File or []byte, binding an Upload does not read or copy the file, so
+// it can be checked before being processed, e.g. using
+//
+//	c.Validation.Check(avatar, mars.ValidMaxFileSize(5<<20), mars.ValidAllowedTypes("image/*")).
+//	  Key("avatar")
+//
+// Multiple files uploaded using the same parameter name can be bound to
+// []Upload.
+type Upload struct {
+	Filename    string // The file name sent by the client, which must not be trusted.
+	Size        int64  // The size of the file in bytes.
+	ContentType string // The MIME type sniffed from the file's contents using http.DetectContentType.
+
+	Header *multipart.FileHeader
+}
+
+// Open opens the uploaded file for reading.
+func (u Upload) Open() (multipart.File, error) {
+	if u.Header == nil {
+		return nil, errors.New("mars/upload: no file uploaded")
+	}
+	return u.Header.Open()
+}
+
+// MaxUploadSize is the maximum size in bytes of uploaded files bound to action
+// arguments. It may be specified in config as "http.upload.maxsize". The value
+// 0 allows files of any size.
+var MaxUploadSize int64
+
+// AllowedUploadTypes are the MIME types, like "image/png" or "image/*", of
+// uploaded files bound to action arguments. It may be specified in config as a
+// comma-separated list using "http.upload.types". If empty, all types are
+// allowed.
+var AllowedUploadTypes []string
+
+var (
+	errUploadTooLarge = errors.New("uploaded file too large")
+	errUploadType     = errors.New("uploaded file type not allowed")
+)
+
+func init() {
+	OnAppStart(func() {
+		MaxUploadSize = int64(Config.IntDefault("http.upload.maxsize", int(MaxUploadSize)))
+		AllowedUploadTypes = configList("http.upload.types", AllowedUploadTypes)
+	})
+
+	TypeBinders[reflect.TypeOf(Upload{})] = Binder{bindUpload, nil}
+	TypeBinders[reflect.TypeOf(&Upload{})] = Binder{bindUploadPointer, nil}
+	TypeBinders[reflect.TypeOf([]Upload{})] = Binder{bindUploads, nil}
+}
+
+// NewUpload returns the Upload for the given file, sniffing its content type.
+func NewUpload(fileHeader *multipart.FileHeader) (Upload, error) {
+	contentType, err := sniffContentType(fileHeader)
+	if err != nil {
+		return Upload{}, err
+	}
+	return Upload{
+		Filename:    fileHeader.Filename,
+		Size:        fileHeader.Size,
+		ContentType: contentType,
+		Header:      fileHeader,
+	}, nil
+}
+
+// sniffContentType determines the content type of the uploaded file using up
+// to the first 512 bytes.
+func sniffContentType(fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// checkUpload checks the uploaded file against MaxUploadSize and
+// AllowedUploadTypes.
+func checkUpload(upload Upload) error {
+	if MaxUploadSize > 0 && upload.Size > MaxUploadSize {
+		return errUploadTooLarge
+	}
+	if len(AllowedUploadTypes) > 0 && !matchesContentType(upload.ContentType, AllowedUploadTypes) {
+		return fmt.Errorf("%w: %s", errUploadType, upload.ContentType)
+	}
+	return nil
+}
+
+// acceptUpload returns the Upload for the given file, if it passes the checks
+// of checkUpload. Otherwise, a BindError is recorded.
+func acceptUpload(params *Params, name string, fileHeader *multipart.FileHeader, typ reflect.Type) (Upload, bool) {
+	upload, err := NewUpload(fileHeader)
+	if err == nil {
+		err = checkUpload(upload)
+	}
+	if err != nil {
+		params.addBindError(name, fileHeader.Filename, typ, err)
+		return Upload{}, false
+	}
+	return upload, true
+}
+
+// matchesContentType checks whether the content type is one of the given
+// types, which may use wildcards like "image/*". Parameters like
+// "; charset=utf-8" are ignored.
+func matchesContentType(contentType string, types []string) bool {
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if t == contentType || t == "*/*" ||
+			(strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func bindUpload(params *Params, name string, typ reflect.Type) reflect.Value {
+	result := reflect.New(typ).Elem()
+	for _, fileHeader := range params.Files[name] {
+		if upload, ok := acceptUpload(params, name, fileHeader, typ); ok {
+			result.Set(reflect.ValueOf(upload))
+		}
+		break
+	}
+	return result
+}
+
+// bindUploadPointer binds the file uploaded using the given name, or nil if it
+// is missing or has been rejected.
+func bindUploadPointer(params *Params, name string, typ reflect.Type) reflect.Value {
+	for _, fileHeader := range params.Files[name] {
+		if upload, ok := acceptUpload(params, name, fileHeader, typ.Elem()); ok {
+			return reflect.ValueOf(&upload)
+		}
+		break
+	}
+	return reflect.Zero(typ)
+}
+
+// bindUploads binds all files uploaded using the given name, as well as the
+// ones using slice syntax, like "name[]" or "name[0]". Files rejected by
+// checkUpload are left out, so they are not counted by MaxFiles. Instead, a
+// BindError is recorded for each of them, which fails the validation, too.
+func bindUploads(params *Params, name string, typ reflect.Type) reflect.Value {
+	result := reflect.MakeSlice(typ, 0, len(params.Files[name]))
+	for _, fileHeader := range params.Files[name] {
+		if upload, ok := acceptUpload(params, name, fileHeader, typ.Elem()); ok {
+			result = reflect.Append(result, reflect.ValueOf(upload))
+		}
+	}
+
+	indexed := bindSlice(params, name, typ)
+	for i := 0; i < indexed.Len(); i++ {
+		if indexed.Index(i).Interface().(Upload).Header != nil {
+			result = reflect.Append(result, indexed.Index(i))
+		}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package mars
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// getUploadParams returns the params of a multipart request uploading a PNG
+// image as "avatar" and two text files as "docs".
+func getUploadParams() *Params {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, file := range []struct {
+		name, filename string
+		content        []byte
+	}{
+		{"avatar", "avatar.png", pngData},
+		{"docs", "a.txt", []byte("first document")},
+		{"docs", "b.txt", bytes.Repeat([]byte("second document"), 100)},
+	} {
+		part, _ := writer.CreateFormFile(file.name, file.filename)
+		part.Write(file.content)
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "http://localhost/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	params := &Params{}
+	ParseParams(params, NewRequest(req))
+	return params
+}
+
+func TestBindUpload(t *testing.T) {
+	params := getUploadParams()
+
+	avatar := Bind(params, "avatar", reflect.TypeOf(Upload{})).Interface().(Upload)
+	eq(t, "Filename", avatar.Filename, "avatar.png")
+	eq(t, "Size", avatar.Size, int64(len(pngData)))
+	eq(t, "ContentType", avatar.ContentType, "image/png")
+	file, err := avatar.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(file)
+	file.Close()
+	if !bytes.Equal(content, pngData) {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	if p := Bind(params, "avatar", reflect.TypeOf(&Upload{})).Interface().(*Upload); p == nil || p.Filename != "avatar.png" {
+		t.Errorf("Unexpected upload pointer %v", p)
+	}
+	if p := Bind(params, "missing", reflect.TypeOf(&Upload{})).Interface().(*Upload); p != nil {
+		t.Errorf("Expected nil for missing upload, got %v", p)
+	}
+	if missing := Bind(params, "missing", reflect.TypeOf(Upload{})).Interface().(Upload); missing.Header != nil {
+		t.Errorf("Expected zero value for missing upload, got %v", missing)
+	}
+
+	docs := Bind(params, "docs", reflect.TypeOf([]Upload{})).Interface().([]Upload)
+	if len(docs) != 2 || docs[0].Filename != "a.txt" || docs[1].ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("Unexpected uploads %v", docs)
+	}
+	if len(params.bindErrors) > 0 {
+		t.Errorf("Unexpected bind errors %v", params.bindErrors)
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	defer func(size int64, types []string) {
+		MaxUploadSize, AllowedUploadTypes = size, types
+	}(MaxUploadSize, AllowedUploadTypes)
+
+	MaxUploadSize = 100
+	AllowedUploadTypes = []string{"image/*", "text/plain"}
+	params := getUploadParams()
+
+	if avatar := Bind(params, "avatar", reflect.TypeOf(Upload{})).Interface().(Upload); avatar.Header == nil {
+		t.Error("Expected PNG upload to be accepted")
+	}
+	docs := Bind(params, "docs", reflect.TypeOf([]Upload{})).Interface().([]Upload)
+	if len(docs) != 1 || docs[0].Filename != "a.txt" {
+		t.Errorf("Expected oversized upload to be rejected, got %v", docs)
+	}
+	eq(t, "size error", len(params.bindErrors), 1)
+	eq(t, "size error message", params.bindErrors[0].Message("en"), "File is too large")
+
+	// Rejected files are bound as nil pointers.
+	AllowedUploadTypes = []string{"text/*"}
+	if p := Bind(params, "avatar", reflect.TypeOf(&Upload{})).Interface().(*Upload); p != nil {
+		t.Errorf("Expected nil for rejected upload, got %v", p)
+	}
+	eq(t, "pointer type error", len(params.bindErrors), 2)
+
+	// Files are rejected before being copied.
+	params = getUploadParams()
+	for _, typ := range []reflect.Type{reflect.TypeOf((*os.File)(nil)), reflect.TypeOf([]byte{})} {
+		if !Bind(params, "avatar", typ).IsNil() {
+			t.Errorf("Expected PNG upload to be rejected for %s", typ)
+		}
+	}
+	if len(params.tmpFiles) > 0 {
+		t.Error("Expected no temp files for rejected uploads")
+	}
+	eq(t, "type errors", len(params.bindErrors), 2)
+	eq(t, "type error message", params.bindErrors[0].Message("en"), "File type is not allowed")
+	eq(t, "type error name", params.bindErrors[0].Name, "avatar")
+}
+
+func TestUploadValidators(t *testing.T) {
+	params := getUploadParams()
+	avatar := Bind(params, "avatar", reflect.TypeOf(Upload{})).Interface().(Upload)
+	docs := Bind(params, "docs", reflect.TypeOf([]Upload{})).Interface().([]Upload)
+
+	performTests(ValidMaxFileSize(100), []Expect{
+		{avatar, true, "small upload"},
+		{&avatar, true, "small upload pointer"},
+		{(*Upload)(nil), true, "missing upload"},
+		{docs, false, "uploads including a large one"},
+		{docs[:1], true, "small uploads"},
+		{"avatar.png", false, "string"},
+	}, t)
+	performTests(ValidAllowedTypes("image/png", "image/jpeg"), []Expect{
+		{avatar, true, "PNG upload"},
+		{Upload{}, true, "missing upload"},
+		{docs, false, "text uploads"},
+	}, t)
+	performTests(ValidMaxFiles(1), []Expect{
+		{docs, false, "two uploads"},
+		{docs[:1], true, "one upload"},
+		{avatar, false, "single upload"},
+	}, t)
+	performTests(ValidRequired(), []Expect{
+		{avatar, true, "upload"},
+		{Upload{}, false, "missing upload"},
+	}, t)
+
+	v := &Validation{}
+	v.Check(avatar, ValidMaxFileSize(5<<20), ValidAllowedTypes("text/*")).Key("avatar")
+	eq(t, "validation error", v.ErrorMap()["avatar"].Message, ValidAllowedTypes("text/*").DefaultMessage())
+}
@@ -207,23 +207,9 @@ func ValidationFilter(c *Controller, fc []Filter) {
 	// values in a cookie. If there previously was a cookie but no errors, remove
 	// the cookie.
 	if errorsValue != "" {
-		c.SetCookie(&http.Cookie{
-			Name:     CookiePrefix + "_ERRORS",
-			Value:    url.QueryEscape(errorsValue),
-			Domain:   CookieDomain,
-			Path:     "/",
-			HttpOnly: CookieHttpOnly,
-			Secure:   CookieSecure,
-		})
+		c.SetCookie(NewCookie("ERRORS", url.QueryEscape(errorsValue)))
 	} else if hasCookie {
-		c.SetCookie(&http.Cookie{
-			Name:     CookiePrefix + "_ERRORS",
-			MaxAge:   -1,
-			Domain:   CookieDomain,
-			Path:     "/",
-			HttpOnly: CookieHttpOnly,
-			Secure:   CookieSecure,
-		})
+		c.SetCookie(removalCookie("ERRORS"))
 	}
 }
 
@@ -234,7 +220,7 @@ func restoreValidationErrors(req *http.Request) ([]*ValidationError, error) {
 		cookie *http.Cookie
 		errors = make([]*ValidationError, 0, 5)
 	)
-	if cookie, err = req.Cookie(CookiePrefix + "_ERRORS"); err == nil {
+	if cookie, err = req.Cookie(cookieName("ERRORS")); err == nil {
 		parseKeyValueCookie(cookie.Value, func(key, val string) {
 			errors = append(errors, &ValidationError{
 				Key:     key,
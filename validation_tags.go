@@ -0,0 +1,262 @@
+package mars
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TagValidators maps the names usable in `validate` struct tags to functions
+// returning the corresponding Validator. They are called with the text
+// following the "=" sign, if any, and the type of the tagged field. Custom
+// validators can be made available to struct tags by adding them here.
+//
+// Example:
+//
+//	type User struct {
+//	    Name  string `validate:"required,min=3"`
+//	    Email string `validate:"required,email"`
+//	    Age   int    `validate:"range=18:120"`
+//	}
+//
+// For strings, slices and maps, "min" and "max" check the length like
+// "minsize" and "maxsize" do. Otherwise, they and "range" are only supported
+// for integers. Pointers are dereferenced, and nil pointers are only checked
+// by "required".
+var TagValidators = map[string]func(arg string, typ reflect.Type) (Validator, error){
+	"required": func(arg string, typ reflect.Type) (Validator, error) {
+		return Required{}, nil
+	},
+	"min": func(arg string, typ reflect.Type) (Validator, error) {
+		n, err := strconv.Atoi(arg)
+		if hasSize(typ) {
+			return MinSize{n}, err
+		}
+		if !isInteger(typ) {
+			return nil, fmt.Errorf("min not supported for %s", typ)
+		}
+		return Min{n}, err
+	},
+	"max": func(arg string, typ reflect.Type) (Validator, error) {
+		n, err := strconv.Atoi(arg)
+		if hasSize(typ) {
+			return MaxSize{n}, err
+		}
+		if !isInteger(typ) {
+			return nil, fmt.Errorf("max not supported for %s", typ)
+		}
+		return Max{n}, err
+	},
+	"range": func(arg string, typ reflect.Type) (Validator, error) {
+		if !isInteger(typ) {
+			return nil, fmt.Errorf("range not supported for %s", typ)
+		}
+		sep := strings.IndexByte(arg, ':')
+		if sep == -1 {
+			return nil, fmt.Errorf("expected range like 1:10, got %q", arg)
+		}
+		min, err := strconv.Atoi(arg[:sep])
+		if err != nil {
+			return nil, err
+		}
+		max, err := strconv.Atoi(arg[sep+1:])
+		return Range{Min{min}, Max{max}}, err
+	},
+	"minsize": func(arg string, typ reflect.Type) (Validator, error) {
+		n, err := strconv.Atoi(arg)
+		return MinSize{n}, err
+	},
+	"maxsize": func(arg string, typ reflect.Type) (Validator, error) {
+		n, err := strconv.Atoi(arg)
+		return MaxSize{n}, err
+	},
+	"length": func(arg string, typ reflect.Type) (Validator, error) {
+		n, err := strconv.Atoi(arg)
+		return Length{n}, err
+	},
+	"email": func(arg string, typ reflect.Type) (Validator, error) {
+		return ValidEmail(), nil
+	},
+	"match": func(arg string, typ reflect.Type) (Validator, error) {
+		regex, err := regexp.Compile(arg)
+		return Match{regex}, err
+	},
+}
+
+func hasSize(typ reflect.Type) bool {
+	switch indirectType(typ).Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func isInteger(typ reflect.Type) bool {
+	switch indirectType(typ).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// taggedField describes a struct field to be validated.
+type taggedField struct {
+	index    []int
+	name     string      // name of the parameter the field is bound from
+	checks   []Validator // validators from the `validate` tag
+	nested   bool        // whether the field may contain tagged structs itself
+	embedded bool        // whether the field is an embedded struct, whose fields are promoted
+}
+
+// taggedFields caches the tagged fields of struct types.
+var taggedFields sync.Map
+
+// ValidateStruct checks the fields of the given struct (or pointer to a
+// struct) against the validators given in their `validate` tags, including
+// nested structs and slices of structs. Errors are keyed by the parameter
+// names the fields are bound from, like "user.Name" for the key "user".
+//
+// Action arguments are validated automatically after binding, so calling this
+// is only needed for values bound by other means, e.g. using Params.BindJSON().
+// Panics, if a tag cannot be parsed.
+func (v *Validation) ValidateStruct(key string, obj interface{}) {
+	v.validateValue(key, reflect.ValueOf(obj), nil)
+}
+
+// validateValue checks the given value like ValidateStruct. Keys contained in
+// skip, e.g. the ones of parameters that could not be bound, are left out
+// along with their fields.
+func (v *Validation) validateValue(key string, value reflect.Value, skip map[string]bool) {
+	if skip[key] {
+		return
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		for _, field := range fieldsToValidate(value.Type()) {
+			fieldKey := field.name
+			if field.embedded {
+				fieldKey = key
+			} else if key != "" {
+				fieldKey = key + "." + field.name
+			}
+			fieldValue := value.FieldByIndex(field.index)
+			if len(field.checks) > 0 && !skip[fieldKey] {
+				v.checkField(fieldKey, fieldValue, field.checks)
+			}
+			if field.nested {
+				v.validateValue(fieldKey, fieldValue, skip)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			v.validateValue(fmt.Sprintf("%s[%d]", key, i), value.Index(i), skip)
+		}
+	}
+}
+
+// checkField checks the value of a field using the given validators. Pointers
+// are dereferenced. Nil pointers are only checked by Required.
+func (v *Validation) checkField(key string, value reflect.Value, checks []Validator) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			for _, check := range checks {
+				if _, ok := check.(Required); ok {
+					v.Check(nil, check).Key(key)
+				}
+			}
+			return
+		}
+		value = value.Elem()
+	}
+	v.Check(validatedValue(value), checks...).Key(key)
+}
+
+// validatedValue returns the value to be passed to validators. All integers
+// are passed as int, as expected by the validators for numbers.
+func validatedValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(value.Uint())
+	}
+	return value.Interface()
+}
+
+// fieldsToValidate returns the fields of the given struct type having a
+// `validate` tag or possibly containing such fields.
+func fieldsToValidate(typ reflect.Type) []taggedField {
+	if fields, ok := taggedFields.Load(typ); ok {
+		return fields.([]taggedField)
+	}
+
+	var fields []taggedField
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		name := structFieldParamName(structField)
+		if (structField.PkgPath != "" && !structField.Anonymous) || name == "" {
+			continue
+		}
+
+		field := taggedField{index: structField.Index, name: name, nested: mayContainTags(structField.Type)}
+		if tag := structField.Tag.Get("validate"); tag != "" && structField.PkgPath == "" {
+			for _, rule := range strings.Split(tag, ",") {
+				check, err := parseValidationRule(strings.TrimSpace(rule), structField.Type)
+				if err != nil {
+					panic(fmt.Errorf("mars/validation: invalid validate tag of %s.%s: %s", typ, structField.Name, err))
+				}
+				field.checks = append(field.checks, check)
+			}
+		}
+
+		// Fields of embedded structs are bound by their own names.
+		field.embedded = structField.Anonymous && structField.Tag.Get("mars") == ""
+		if len(field.checks) > 0 || field.nested {
+			fields = append(fields, field)
+		}
+	}
+
+	taggedFields.Store(typ, fields)
+	return fields
+}
+
+func parseValidationRule(rule string, typ reflect.Type) (Validator, error) {
+	name, arg := rule, ""
+	if sep := strings.IndexByte(rule, '='); sep != -1 {
+		name, arg = rule[:sep], rule[sep+1:]
+	}
+	newValidator, ok := TagValidators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown validator %q", name)
+	}
+	return newValidator(arg, typ)
+}
+
+// mayContainTags checks whether values of the given type may contain structs
+// with fields to be validated.
+func mayContainTags(typ reflect.Type) bool {
+	typ = indirectType(typ)
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = indirectType(typ.Elem())
+	}
+	return typ.Kind() == reflect.Struct && typ != reflect.TypeOf(time.Time{})
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
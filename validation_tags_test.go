@@ -0,0 +1,171 @@
+package mars
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type taggedAddress struct {
+	City string `mars:"city" validate:"required"`
+	Zip  string `validate:"length=5"`
+}
+
+type taggedBase struct {
+	ID int `validate:"min=1"`
+}
+
+type taggedUser struct {
+	taggedBase
+	Name     string          `mars:"name" validate:"required,min=3"`
+	Email    string          `mars:"email" validate:"required,email"`
+	Age      uint8           `validate:"range=18:120"`
+	Tags     []string        `validate:"maxsize=2"`
+	Address  *taggedAddress  `mars:"address"`
+	Previous []taggedAddress `mars:"previous"`
+	Password string          `mars:"-" validate:"required"`
+	ignored  string          `validate:"required"`
+}
+
+func validationKeys(v *Validation) []string {
+	keys := []string{}
+	for _, err := range v.Errors {
+		keys = append(keys, err.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestValidateStruct(t *testing.T) {
+	v := &Validation{}
+	v.ValidateStruct("user", taggedUser{
+		taggedBase: taggedBase{ID: 1},
+		Name:       "Rob",
+		Email:      "rob@example.com",
+		Age:        42,
+		Address:    &taggedAddress{City: "Berlin", Zip: "10115"},
+		Password:   "secret",
+	})
+	if v.HasErrors() {
+		t.Errorf("Expected no validation errors, got %v", validationKeys(v))
+	}
+
+	v = &Validation{}
+	v.ValidateStruct("user", &taggedUser{
+		Name:     "Ro",
+		Email:    "rob",
+		Age:      12,
+		Tags:     []string{"a", "b", "c"},
+		Address:  &taggedAddress{Zip: "1"},
+		Previous: []taggedAddress{{City: "Berlin", Zip: "10115"}, {Zip: "10115"}},
+	})
+	expected := []string{
+		"user.Age", "user.ID", "user.Tags", "user.address.Zip", "user.address.city",
+		"user.email", "user.name", "user.previous[1].city",
+	}
+	if keys := validationKeys(v); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected validation errors for %v, got %v", expected, keys)
+	}
+	eq(t, "message", v.ErrorMap()["user.name"].Message, MinSize{3}.DefaultMessage())
+}
+
+func TestValidateStructSkipsUnbound(t *testing.T) {
+	params := &Params{Values: url.Values{
+		"user.ID":    {"abc"},
+		"user.email": {"rob@example.com"},
+	}}
+	user := Bind(params, "user", reflect.TypeOf(taggedUser{}))
+	eq(t, "bind errors", len(params.bindErrors), 1)
+
+	v := &Validation{}
+	v.validateValue("user", user, map[string]bool{params.bindErrors[0].Name: true})
+	expected := []string{"user.Age", "user.name"}
+	if keys := validationKeys(v); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected validation errors for %v, got %v", expected, keys)
+	}
+
+	v = &Validation{}
+	v.validateValue("user", user, map[string]bool{"user": true})
+	if v.HasErrors() {
+		t.Errorf("Expected no validation errors for unbound struct, got %v", validationKeys(v))
+	}
+}
+
+func TestValidateStructPointers(t *testing.T) {
+	type profile struct {
+		Age      *int    `validate:"min=18"`
+		Name     *string `validate:"required,min=3"`
+		Nickname *string `validate:"max=5"`
+	}
+
+	age, name := 30, "Alice"
+	v := &Validation{}
+	v.ValidateStruct("", profile{Age: &age, Name: &name})
+	if v.HasErrors() {
+		t.Errorf("Expected no validation errors, got %v", validationKeys(v))
+	}
+
+	age, name = 12, "Al"
+	v = &Validation{}
+	v.ValidateStruct("", profile{Age: &age, Name: &name})
+	if keys := validationKeys(v); !reflect.DeepEqual(keys, []string{"Age", "Name"}) {
+		t.Errorf("Expected validation errors for Age and Name, got %v", keys)
+	}
+
+	// Nil pointers are only checked by required.
+	v = &Validation{}
+	v.ValidateStruct("", profile{})
+	if keys := validationKeys(v); !reflect.DeepEqual(keys, []string{"Name"}) {
+		t.Errorf("Expected validation error for Name, got %v", keys)
+	}
+}
+
+func TestValidateStructInvalidTag(t *testing.T) {
+	for _, obj := range []interface{}{
+		struct {
+			Name string `validate:"unknown"`
+		}{},
+		struct {
+			Price float64 `validate:"min=1"`
+		}{},
+		struct {
+			Created time.Time `validate:"range=1:2"`
+		}{},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected panic for invalid validate tag of %T", obj)
+				}
+			}()
+			(&Validation{}).ValidateStruct("", obj)
+		}()
+	}
+}
+
+func TestBindTaggedStruct(t *testing.T) {
+	params := &Params{Values: url.Values{
+		"user.ID":               {"5"},
+		"user.name":             {"Rob"},
+		"user.Name":             {"Bob"},
+		"user.email":            {"rob@example.com"},
+		"user.address.city":     {"Berlin"},
+		"user.previous[0].city": {"Hamburg"},
+		"user.Password":         {"secret"},
+	}}
+	user := Bind(params, "user", reflect.TypeOf(taggedUser{})).Interface().(taggedUser)
+	eq(t, "ID", user.ID, 5)
+	eq(t, "Name", user.Name, "Rob")
+	eq(t, "Email", user.Email, "rob@example.com")
+	eq(t, "Address.City", user.Address.City, "Berlin")
+	eq(t, "Previous[0].City", user.Previous[0].City, "Hamburg")
+	eq(t, "Password", user.Password, "")
+
+	output := map[string]string{}
+	Unbind(output, "user", taggedAddress{City: "Berlin", Zip: "10115"})
+	if !reflect.DeepEqual(output, map[string]string{"user.city": "Berlin", "user.Zip": "10115"}) {
+		t.Errorf("Unexpected unbound values %v", output)
+	}
+}
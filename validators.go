@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -35,6 +36,9 @@ func (r Required) IsSatisfied(obj interface{}) bool {
 	if t, ok := obj.(time.Time); ok {
 		return !t.IsZero()
 	}
+	if u, ok := obj.(Upload); ok {
+		return u.Header != nil
+	}
 	v := reflect.ValueOf(obj)
 	if v.Kind() == reflect.Slice {
 		return v.Len() > 0
@@ -207,3 +211,86 @@ func ValidEmail() Email {
 func (e Email) DefaultMessage() string {
 	return fmt.Sprintln("Must be a valid email address")
 }
+
+// Requires uploaded files to be at most a given number of bytes in size.
+// Accepts Upload, *Upload and []Upload.
+type MaxFileSize struct {
+	Max int64
+}
+
+func ValidMaxFileSize(max int64) MaxFileSize {
+	return MaxFileSize{max}
+}
+
+func (m MaxFileSize) IsSatisfied(obj interface{}) bool {
+	return allUploads(obj, func(u Upload) bool {
+		return u.Size <= m.Max
+	})
+}
+
+func (m MaxFileSize) DefaultMessage() string {
+	return fmt.Sprintln("Maximum file size is", m.Max, "bytes")
+}
+
+// Requires uploaded files to have one of the given MIME types, like
+// "image/png" or "image/*", as sniffed from their contents.
+// Accepts Upload, *Upload and []Upload.
+type AllowedTypes struct {
+	Types []string
+}
+
+func ValidAllowedTypes(types ...string) AllowedTypes {
+	return AllowedTypes{types}
+}
+
+func (a AllowedTypes) IsSatisfied(obj interface{}) bool {
+	return allUploads(obj, func(u Upload) bool {
+		return u.Header == nil || matchesContentType(u.ContentType, a.Types)
+	})
+}
+
+func (a AllowedTypes) DefaultMessage() string {
+	return fmt.Sprintln("Allowed file types are", strings.Join(a.Types, ", "))
+}
+
+// Requires at most a given number of files to be uploaded. Only the files bound
+// to []Upload are counted, which leaves out the ones rejected because of
+// MaxUploadSize or AllowedUploadTypes. These are reported as validation errors
+// of their own.
+type MaxFiles struct {
+	Max int
+}
+
+func ValidMaxFiles(max int) MaxFiles {
+	return MaxFiles{max}
+}
+
+func (m MaxFiles) IsSatisfied(obj interface{}) bool {
+	if uploads, ok := obj.([]Upload); ok {
+		return len(uploads) <= m.Max
+	}
+	return false
+}
+
+func (m MaxFiles) DefaultMessage() string {
+	return fmt.Sprintln("Maximum number of files is", m.Max)
+}
+
+// allUploads checks whether all given uploads satisfy the check. Returns false
+// for anything but uploads.
+func allUploads(obj interface{}, check func(Upload) bool) bool {
+	switch u := obj.(type) {
+	case Upload:
+		return check(u)
+	case *Upload:
+		return u == nil || check(*u)
+	case []Upload:
+		for _, upload := range u {
+			if !check(upload) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}